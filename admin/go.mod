@@ -0,0 +1,5 @@
+module votacao-rabbitmq/admin
+
+go 1.22
+
+require github.com/rabbitmq/amqp091-go v1.9.0
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Comando enviado ao canal de controle do servidor.
+type Comando struct {
+	Cmd     string `json:"cmd"`
+	Token   string `json:"token"`
+	Arquivo string `json:"arquivo,omitempty"`
+	Formato string `json:"format,omitempty"`
+	// Segundos do "extend" e do "shorten".
+	Segundos int `json:"seconds,omitempty"`
+	// "dump-state": encerra o servidor depois de gravar.
+	Parar bool `json:"stop,omitempty"`
+}
+
+// Resposta do servidor a um comando.
+type RespostaControle struct {
+	Cmd      string            `json:"cmd"`
+	Ok       bool              `json:"ok"`
+	Mensagem string            `json:"mensagem,omitempty"`
+	Parte    int               `json:"parte,omitempty"`
+	Total    int               `json:"total,omitempty"`
+	Votos    map[string]string `json:"votos,omitempty"`
+
+	Resultado map[string]int `json:"resultado,omitempty"`
+	Vencedor  string         `json:"vencedor,omitempty"`
+	Final     bool           `json:"final,omitempty"`
+	Corpo     string         `json:"corpo,omitempty"`
+}
+
+// Comandos aceitos pelo servidor.
+var comandos = map[string]string{
+	"close":    "encerra a votação agora e publica o resultado final",
+	"pause":    "pausa a votação (votos são rejeitados e o prazo para)",
+	"resume":   "retoma uma votação pausada",
+	"reset":    "descarta os votos e recomeça a votação com o prazo completo",
+	"extend":   "soma -seconds ao tempo restante",
+	"shorten":  "tira -seconds do tempo restante (até zero, que encerra a votação)",
+	"audit":    "mostra o mapa completo usuário -> opção (-arquivo grava no servidor)",
+	"export":   "mostra a contagem atual ou final (-format json|csv)",
+	"snapshot": "publica agora uma parcial com a contagem atual",
+
+	"dump-state": "grava o estado completo em -arquivo no servidor (-stop encerra o servidor para outro assumir)",
+}
+
+func uso() {
+	fmt.Fprintln(os.Stderr, "Uso: admin [-timeout 5s] <comando> [opções]")
+	fmt.Fprintln(os.Stderr, "\nO token é lido da variável ADMIN_TOKEN.\n\nComandos:")
+	for _, c := range []string{"close", "pause", "resume", "reset", "extend", "shorten", "audit", "export", "snapshot", "dump-state"} {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c, comandos[c])
+	}
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "verify", "confere um recibo de voto localmente (segredo em RECEIPT_SECRET)")
+}
+
+// Confere um recibo HMAC emitido pelo servidor, sem acessar o broker.
+// A mensagem assinada é userId, opção e timestamp, um por linha.
+func verificar(args []string) {
+	sub := flag.NewFlagSet("verify", flag.ExitOnError)
+	user := sub.String("user", "", "userId do eleitor")
+	opcao := sub.String("opcao", "", "opção do recibo (várias separadas por vírgula)")
+	timestamp := sub.String("timestamp", "", "timestamp do recibo")
+	assinatura := sub.String("hmac", "", "hmac do recibo, em hexadecimal")
+	sub.Parse(args)
+
+	segredo := os.Getenv("RECEIPT_SECRET")
+	if segredo == "" {
+		log.Fatal("Defina RECEIPT_SECRET com o segredo do servidor.")
+	}
+
+	esperado, err := hex.DecodeString(*assinatura)
+	if err != nil {
+		log.Fatalf("hmac inválido: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(segredo))
+	mac.Write([]byte(*user + "\n" + *opcao + "\n" + *timestamp))
+	if !hmac.Equal(esperado, mac.Sum(nil)) {
+		fmt.Println("Recibo inválido.")
+		os.Exit(1)
+	}
+	fmt.Println("Recibo válido.")
+}
+
+func main() {
+	flag.Usage = uso
+	timeout := flag.Duration("timeout", 5*time.Second, "tempo máximo de espera pela resposta")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		uso()
+		os.Exit(2)
+	}
+
+	if flag.Arg(0) == "verify" {
+		verificar(flag.Args()[1:])
+		return
+	}
+
+	cmd := Comando{Cmd: flag.Arg(0), Token: os.Getenv("ADMIN_TOKEN")}
+	if _, ok := comandos[cmd.Cmd]; !ok {
+		fmt.Fprintf(os.Stderr, "Comando desconhecido: %s\n\n", cmd.Cmd)
+		uso()
+		os.Exit(2)
+	}
+	if cmd.Token == "" {
+		log.Fatal("Defina ADMIN_TOKEN com o token do servidor.")
+	}
+
+	// Opções específicas do subcomando.
+	sub := flag.NewFlagSet(cmd.Cmd, flag.ExitOnError)
+	arquivo := sub.String("arquivo", "", "audit: grava o mapa neste arquivo do ADMIN_FILES_DIR do servidor em vez de exibir; dump-state: arquivo do estado")
+	formato := sub.String("format", "json", "export: formato da contagem (json ou csv)")
+	segundos := sub.Int("seconds", 0, "extend/shorten: segundos somados ou tirados do prazo")
+	parar := sub.Bool("stop", false, "dump-state: encerra o servidor logo após gravar")
+	sub.Parse(flag.Args()[1:])
+	cmd.Arquivo = *arquivo
+	if cmd.Cmd == "dump-state" {
+		if *arquivo == "" {
+			log.Fatal("dump-state exige -arquivo.")
+		}
+		cmd.Parar = *parar
+	}
+	if cmd.Cmd == "export" {
+		cmd.Formato = *formato
+	}
+	if cmd.Cmd == "extend" || cmd.Cmd == "shorten" {
+		if *segundos <= 0 {
+			log.Fatalf("%s exige -seconds maior que zero.", cmd.Cmd)
+		}
+		cmd.Segundos = *segundos
+	}
+
+	// Prefixo das exchanges, igual ao EXCHANGE_PREFIX do servidor.
+	exchangeControle := "votacao.controle"
+	if v := os.Getenv("EXCHANGE_PREFIX"); v != "" {
+		exchangeControle = v + ".controle"
+	}
+
+	// Conexão com RabbitMQ: os nós de RABBITMQ_URLS, tentados em ordem.
+	conn, err := conectar(lerURLs(os.Getenv("RABBITMQ_URLS")))
+	if err != nil {
+		log.Fatalf("Erro ao conectar com RabbitMQ: %v", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		log.Fatalf("Erro ao abrir canal: %v", err)
+	}
+	defer ch.Close()
+
+	// Fila exclusiva para receber a resposta do servidor.
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		log.Fatalf("Erro ao declarar fila de resposta: %v", err)
+	}
+	respostas, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		log.Fatalf("Erro ao consumir fila de resposta: %v", err)
+	}
+
+	body, _ := json.Marshal(cmd)
+	correlacao := fmt.Sprintf("admin-%d-%d", os.Getpid(), time.Now().UnixNano())
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	err = ch.PublishWithContext(
+		ctx,
+		exchangeControle,
+		"comando",
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			ReplyTo:       q.Name,
+			CorrelationId: correlacao,
+			Body:          body,
+		},
+	)
+	if err != nil {
+		log.Fatalf("Erro ao enviar comando: %v", err)
+	}
+
+	// A auditoria chega em partes; os demais comandos têm resposta única.
+	votos := map[string]string{}
+	for {
+		select {
+		case <-ctx.Done():
+			log.Fatalf("Sem resposta do servidor em %v (canal de controle habilitado?).", *timeout)
+
+		case d, ok := <-respostas:
+			if !ok {
+				log.Fatal("Conexão encerrada antes da resposta.")
+			}
+			if d.CorrelationId != correlacao {
+				continue
+			}
+
+			var resp RespostaControle
+			if err := json.Unmarshal(d.Body, &resp); err != nil {
+				log.Fatalf("Resposta inválida: %v", err)
+			}
+			if !resp.Ok {
+				fmt.Fprintf(os.Stderr, "Erro: %s\n", resp.Mensagem)
+				os.Exit(1)
+			}
+
+			if cmd.Cmd == "export" {
+				if cmd.Formato == "csv" {
+					fmt.Print(resp.Corpo)
+					return
+				}
+				out, _ := json.MarshalIndent(struct {
+					Resultado map[string]int `json:"resultado"`
+					Vencedor  string         `json:"vencedor,omitempty"`
+					Final     bool           `json:"final"`
+				}{resp.Resultado, resp.Vencedor, resp.Final}, "", "  ")
+				fmt.Println(string(out))
+				return
+			}
+
+			if resp.Total == 0 {
+				fmt.Println(resp.Mensagem)
+				return
+			}
+
+			for k, v := range resp.Votos {
+				votos[k] = v
+			}
+			if resp.Parte >= resp.Total {
+				out, _ := json.MarshalIndent(votos, "", "  ")
+				fmt.Println(string(out))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+//
+// Modo -watch-user: acompanha o voto de outro usuário (atendimento).
+//
+// As confirmações e os erros do servidor saem no broadcast (com
+// BROADCAST_LANGS, uma cópia na rota de cada idioma da lista), então
+// basta filtrar pelo UserID. Se o servidor passar a confirmar em filas
+// diretas por usuário, esse filtro não verá mais nada e o modo deve
+// depender só da consulta à auditoria abaixo.
+//
+
+// Comando "audit" do canal de controle e a parte da resposta usada aqui.
+type comandoAuditoria struct {
+	Cmd   string `json:"cmd"`
+	Token string `json:"token"`
+}
+
+type respostaAuditoria struct {
+	Ok       bool              `json:"ok"`
+	Mensagem string            `json:"mensagem,omitempty"`
+	Parte    int               `json:"parte,omitempty"`
+	Total    int               `json:"total,omitempty"`
+	Votos    map[string]string `json:"votos,omitempty"`
+}
+
+// Imprime confirmações e erros do usuário até o resultado final,
+// reconectando se a conexão cair.
+func acompanharUsuario(sessao *Sessao, msgs <-chan amqp.Delivery, alvo string, verbose bool) {
+	fmt.Printf("Acompanhando os votos de %s. Ctrl+C para sair.\n", alvo)
+	for {
+		for m := range msgs {
+			body, err := lerCorpo(m)
+			if err != nil {
+				continue
+			}
+			var msg BroadcastMsg
+			if err := codecDoTipo(m.ContentType).Unmarshal(body, &msg); err != nil {
+				continue
+			}
+
+			agora := time.Now().Format("15:04:05")
+			switch msg.Tipo {
+			case "confirmacao":
+				if msg.UserID != alvo {
+					continue
+				}
+				if verbose {
+					log.Printf("[recebido] %s", legivel(m.ContentType, body))
+				}
+				if msg.Anterior != "" {
+					fmt.Printf("[%s] Confirmado: %s -> %s (%s)\n", agora, msg.Anterior, msg.Opcao, msg.Mensagem)
+				} else {
+					fmt.Printf("[%s] Confirmado: %s (%s)\n", agora, msg.Opcao, msg.Mensagem)
+				}
+			case "erro":
+				if msg.UserID != alvo {
+					continue
+				}
+				if verbose {
+					log.Printf("[recebido] %s", legivel(m.ContentType, body))
+				}
+				fmt.Printf("[%s] Recusado: %s (%s)\n", agora, msg.Mensagem, msg.Codigo)
+			case "final":
+				fmt.Printf("[%s] Votação encerrada.\n", agora)
+				return
+			}
+		}
+		log.Println("Conexão perdida; reconectando...")
+		msgs = sessao.reconectar()
+	}
+}
+
+// Consulta o voto atual do usuário pelo comando "audit" (exige o
+// ADMIN_TOKEN do servidor). Devolve "" quando ele ainda não votou.
+func consultarAuditoria(ch *amqp.Channel, prefixo, token, user string) (string, error) {
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return "", err
+	}
+	respostas, err := ch.Consume(q.Name, q.Name, true, true, false, false, nil)
+	if err != nil {
+		return "", err
+	}
+	defer ch.Cancel(q.Name, false)
+
+	body, _ := json.Marshal(comandoAuditoria{Cmd: "audit", Token: token})
+	correlacao := fmt.Sprintf("watch-%d-%d", os.Getpid(), time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = ch.PublishWithContext(ctx, prefixo+".controle", "comando", false, false, amqp.Publishing{
+		ContentType:   "application/json",
+		ReplyTo:       q.Name,
+		CorrelationId: correlacao,
+		Body:          body,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// A auditoria chega em partes; o usuário pode estar em qualquer uma.
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("sem resposta do servidor (canal de controle habilitado?)")
+		case d, ok := <-respostas:
+			if !ok {
+				return "", fmt.Errorf("conexão encerrada antes da resposta")
+			}
+			if d.CorrelationId != correlacao {
+				continue
+			}
+			var resp respostaAuditoria
+			if err := json.Unmarshal(d.Body, &resp); err != nil {
+				return "", err
+			}
+			if !resp.Ok {
+				return "", fmt.Errorf("%s", resp.Mensagem)
+			}
+			if opcao, ok := resp.Votos[user]; ok {
+				return opcao, nil
+			}
+			if resp.Total == 0 || resp.Parte >= resp.Total {
+				return "", nil
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+)
+
+// Cédula do modo -anonimo: hash do identificador da máquina com o prefixo
+// das exchanges. O mesmo aparelho gera a mesma cédula na mesma votação,
+// mas cédulas de votações com prefixos diferentes não se ligam entre si.
+// Sem /etc/machine-id, usa o hostname.
+func gerarCedula(prefixo string) string {
+	dispositivo, err := os.ReadFile("/etc/machine-id")
+	if err != nil || strings.TrimSpace(string(dispositivo)) == "" {
+		nome, _ := os.Hostname()
+		dispositivo = []byte(nome)
+	}
+	soma := sha256.Sum256([]byte(strings.TrimSpace(string(dispositivo)) + "\n" + prefixo))
+	return hex.EncodeToString(soma[:])
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+//
+// Codificação das mensagens de voto e de broadcast (CODEC).
+//
+// O voto sai no codec configurado e cada broadcast é decodificado pelo
+// ContentType dele, como no servidor. O MessagePack usa as mesmas chaves
+// das tags json.
+//
+
+// Codec serializa as mensagens trocadas pelo AMQP.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+const (
+	tipoJSON    = "application/json"
+	tipoMsgpack = "application/msgpack"
+)
+
+type codecJSON struct{}
+
+func (codecJSON) ContentType() string                { return tipoJSON }
+func (codecJSON) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (codecJSON) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type codecMsgpack struct{}
+
+func (codecMsgpack) ContentType() string { return tipoMsgpack }
+
+func (codecMsgpack) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (codecMsgpack) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// Codec pelo nome configurado em CODEC.
+func novoCodec(nome string) (Codec, error) {
+	switch nome {
+	case "", "json":
+		return codecJSON{}, nil
+	case "msgpack":
+		return codecMsgpack{}, nil
+	}
+	return nil, fmt.Errorf("codec desconhecido %q (use json ou msgpack)", nome)
+}
+
+// Codec de uma mensagem recebida; sem ContentType conhecido, JSON.
+func codecDoTipo(contentType string) Codec {
+	switch contentType {
+	case tipoMsgpack, "application/x-msgpack":
+		return codecMsgpack{}
+	}
+	return codecJSON{}
+}
+
+// Corpo em JSON para o modo verboso, qualquer que seja o codec.
+func legivel(contentType string, body []byte) []byte {
+	c := codecDoTipo(contentType)
+	if c.ContentType() == tipoJSON {
+		return body
+	}
+	var v map[string]any
+	if err := c.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, _ := json.Marshal(v)
+	return out
+}
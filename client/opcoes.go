@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opções válidas da votação. Começam como A, B, C e são atualizadas
+// pelo broadcast "opcoes" que o servidor envia ao iniciar a votação e
+// pelas cópias no "online" e em cada heartbeat, que alcançam quem
+// conectou depois.
+type OpcoesVotacao struct {
+	// Fechado no primeiro anúncio recebido (ver aguardar).
+	anunciadas chan struct{}
+	avisar     sync.Once
+
+	mu    sync.Mutex
+	lista []string
+	// Intervalo numérico, usado quando a lista está vazia.
+	min, max int
+	// Rótulos de exibição anunciados pelo servidor (chave -> texto).
+	rotulos map[string]string
+	// Cores anunciadas ("#rrggbb"), usadas só quando colorir é verdadeiro.
+	cores   map[string]string
+	colorir bool
+	// Apuração por aprovação (TALLY=approval): o voto leva quantas
+	// opções o eleitor quiser.
+	aprovacao bool
+	// Apuração por ranking (TALLY=irv): o voto leva as opções em ordem
+	// de preferência.
+	ranking bool
+	// Bits zero da prova de trabalho exigida pelo servidor; 0 = nenhuma.
+	dificuldade int
+}
+
+// Cores só em terminal e sem NO_COLOR, para não sujar saídas redirecionadas.
+func novasOpcoes() *OpcoesVotacao {
+	return &OpcoesVotacao{lista: []string{"A", "B", "C"}, colorir: terminalColorido(), anunciadas: make(chan struct{})}
+}
+
+func terminalColorido() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Substitui as opções pelas anunciadas pelo servidor e informa se algo
+// mudou. Mensagens sem opções (servidor antigo) são ignoradas.
+func (o *OpcoesVotacao) atualizar(msg BroadcastMsg) bool {
+	if len(msg.Opcoes) == 0 && msg.Intervalo == "" {
+		return false
+	}
+	defer o.avisar.Do(func() { close(o.anunciadas) })
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	antes := o.assinatura()
+	o.aprovacao = msg.Apuracao == "approval"
+	o.ranking = msg.Apuracao == "irv"
+	o.dificuldade = msg.DificuldadePoW
+	if msg.Intervalo != "" {
+		minStr, maxStr, _ := strings.Cut(msg.Intervalo, "-")
+		min, err1 := strconv.Atoi(minStr)
+		max, err2 := strconv.Atoi(maxStr)
+		if err1 == nil && err2 == nil {
+			o.lista, o.min, o.max, o.rotulos, o.cores = nil, min, max, nil, nil
+		}
+	} else {
+		o.lista = append([]string(nil), msg.Opcoes...)
+		o.rotulos = msg.Rotulos
+		o.cores = msg.Cores
+	}
+	return o.assinatura() != antes
+}
+
+// Resumo comparável do conjunto de opções; chamado com o mu travado.
+func (o *OpcoesVotacao) assinatura() string {
+	return fmt.Sprint(o.lista, o.min, o.max, o.rotulos, o.cores, o.aprovacao, o.ranking, o.dificuldade)
+}
+
+// Espera o primeiro anúncio das opções por até limite. Devolve false se
+// ele não chegou e as opções ainda são as padrão.
+func (o *OpcoesVotacao) aguardar(limite time.Duration) bool {
+	select {
+	case <-o.anunciadas:
+		return true
+	case <-time.After(limite):
+		return false
+	}
+}
+
+// Chave da opção acompanhada do rótulo, quando houver: "B — Rust".
+func (o *OpcoesVotacao) exibir(op string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.comRotulo(op)
+}
+
+// Igual a exibir, chamado com o mu travado.
+func (o *OpcoesVotacao) comRotulo(op string) string {
+	texto := op
+	if r := o.rotulos[op]; r != "" {
+		texto = op + " — " + r
+	}
+	if o.colorir {
+		if seq, ok := corANSI(o.cores[op]); ok {
+			return seq + texto + "\033[0m"
+		}
+	}
+	return texto
+}
+
+// Sequência ANSI de cor de texto (24 bits) para "#rrggbb"; outros
+// formatos são ignorados.
+func corANSI(cor string) (string, bool) {
+	hex, ok := strings.CutPrefix(cor, "#")
+	if !ok || len(hex) != 6 {
+		return "", false
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("\033[38;2;%d;%d;%dm", rgb>>16, rgb>>8&0xff, rgb&0xff), true
+}
+
+// Máximo de opções por voto: todas na aprovação e no ranking, senão o
+// -max-opcoes.
+func (o *OpcoesVotacao) maxEscolhas(padrao int) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.aprovacao && !o.ranking {
+		return padrao
+	}
+	if len(o.lista) == 0 {
+		return o.max - o.min + 1
+	}
+	return len(o.lista)
+}
+
+// Informa se a votação é por aprovação.
+func (o *OpcoesVotacao) porAprovacao() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.aprovacao
+}
+
+// Dificuldade da prova de trabalho anunciada pelo servidor.
+func (o *OpcoesVotacao) dificuldadePoW() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.dificuldade
+}
+
+// Informa se a votação é por ranking.
+func (o *OpcoesVotacao) porRanking() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.ranking
+}
+
+// Texto exibido no prompt de voto.
+func (o *OpcoesVotacao) descricao() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.lista) == 0 {
+		return fmt.Sprintf("de %d a %d", o.min, o.max)
+	}
+	itens := make([]string, len(o.lista))
+	for i, op := range o.lista {
+		itens[i] = o.comRotulo(op)
+	}
+	return strings.Join(itens, ", ")
+}
+
+// Converte a entrada do usuário na opção como o servidor a conhece
+// (ignorando maiúsculas/minúsculas). Retorna false se não for válida.
+func (o *OpcoesVotacao) normalizar(entrada string) (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entrada = strings.TrimSpace(entrada)
+
+	if len(o.lista) == 0 {
+		n, err := strconv.Atoi(entrada)
+		if err != nil || n < o.min || n > o.max {
+			return "", false
+		}
+		return strconv.Itoa(n), true
+	}
+
+	for _, op := range o.lista {
+		if strings.EqualFold(op, entrada) {
+			return op, true
+		}
+	}
+	return "", false
+}
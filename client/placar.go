@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Deltas fora de ordem tolerados antes de considerar que um se perdeu:
+// os workers do servidor publicam em paralelo e podem inverter parciais.
+const limiteLacuna = 3
+
+// Placar local montado a partir das parciais. No modo delta do servidor
+// (PARTIAL_DELTAS) cada parcial traz só a variação desde a anterior;
+// parciais completas servem de base e corrigem lacunas.
+type Placar struct {
+	contagem map[string]int
+	seq      int64
+	// Já recebeu uma parcial completa para aplicar os deltas.
+	base bool
+	// Deltas recebidos antes dos que os precedem na sequência.
+	pendentes map[int64]map[string]int
+	// Pedido de parcial completa ainda sem resposta.
+	pedido bool
+}
+
+func novoPlacar() *Placar {
+	return &Placar{contagem: map[string]int{}, pendentes: map[int64]map[string]int{}}
+}
+
+// Aplica a parcial. emDia indica que a contagem local reflete o
+// servidor; pedir, que há uma lacuna e o cliente deve pedir uma parcial
+// completa (informado uma vez por pedido).
+func (p *Placar) aplicar(msg BroadcastMsg) (emDia, pedir bool) {
+	// Servidor sem modo delta ou parcial completa: substitui a contagem.
+	if msg.Seq == 0 || msg.Completa {
+		if p.base && msg.Seq < p.seq {
+			// Completa antiga chegando depois de deltas mais novos.
+			return len(p.pendentes) == 0, false
+		}
+		p.contagem = copiaContagem(msg.Result)
+		p.seq, p.base, p.pedido = msg.Seq, true, false
+		for s := range p.pendentes {
+			if s <= p.seq {
+				delete(p.pendentes, s)
+			}
+		}
+		p.avancar()
+		return len(p.pendentes) == 0, false
+	}
+
+	if p.base && msg.Seq <= p.seq {
+		return len(p.pendentes) == 0, false
+	}
+	p.pendentes[msg.Seq] = msg.Delta
+	if p.base {
+		p.avancar()
+	}
+
+	if !p.base || len(p.pendentes) >= limiteLacuna {
+		pedir = !p.pedido
+		p.pedido = true
+		return false, pedir
+	}
+	return len(p.pendentes) == 0, false
+}
+
+// Aplica os deltas pendentes que continuam a sequência.
+func (p *Placar) avancar() {
+	for {
+		delta, ok := p.pendentes[p.seq+1]
+		if !ok {
+			return
+		}
+		for op, d := range delta {
+			p.contagem[op] += d
+		}
+		p.seq++
+		delete(p.pendentes, p.seq)
+	}
+}
+
+func copiaContagem(orig map[string]int) map[string]int {
+	c := make(map[string]int, len(orig))
+	for k, v := range orig {
+		c[k] = v
+	}
+	return c
+}
+
+// Pede ao servidor uma parcial completa, publicando na exchange de votos
+// com a routing key "snapshot".
+func pedirParcialCompleta(ch *amqp.Channel, exchange string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return ch.PublishWithContext(ctx, exchange, "snapshot", false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        []byte("{}"),
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Sessao mantém a conexão com o broker e a fila exclusiva de broadcast,
+// reabrindo as duas quando a conexão cai ou é derrubada pelo watchdog.
+type Sessao struct {
+	urls    []string
+	cfg     amqp.Config
+	prefixo string
+	// Idioma das confirmações e erros recebidos (rota "broadcast.<lang>").
+	lang string
+	// Tag do consumidor, visível no painel do RabbitMQ.
+	tag string
+	// Espera entre tentativas de reconexão.
+	backoff Backoff
+
+	mu   sync.Mutex
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+// Conecta, declara a fila exclusiva ligada ao broadcast e começa a
+// consumir.
+func (s *Sessao) abrir() (<-chan amqp.Delivery, error) {
+	conn, _, err := conectar(s.urls, s.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Alarme de memória/disco no broker: os votos ficam presos até o
+	// desbloqueio, então avisamos em vez de esperar o timeout.
+	bloqueios := conn.NotifyBlocked(make(chan amqp.Blocking, 4))
+	go func() {
+		for b := range bloqueios {
+			if b.Active {
+				fmt.Printf("\nBroker aplicando controle de fluxo (blocked): %s. Envios podem demorar.\n", b.Reason)
+			} else {
+				fmt.Println("\nBroker liberou os envios (unblocked).")
+			}
+		}
+	}()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("erro ao abrir canal: %w", err)
+	}
+
+	// Fila exclusiva para receber mensagens de broadcast.
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("erro ao declarar fila: %w", err)
+	}
+	// Rotas da exchange topic de um servidor com BROADCAST_LANGS; num
+	// fanout as duas são ignoradas e cada mensagem chega uma vez só.
+	for _, rota := range []string{"broadcast", "broadcast." + s.lang} {
+		if err := ch.QueueBind(q.Name, rota, s.prefixo+".broadcast", false, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("erro ao associar fila à exchange: %w", err)
+		}
+	}
+	msgs, err := ch.Consume(q.Name, s.tag, true, true, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("erro ao iniciar consumo de mensagens: %w", err)
+	}
+
+	s.mu.Lock()
+	s.conn, s.ch = conn, ch
+	s.mu.Unlock()
+	return msgs, nil
+}
+
+// Tenta abrir a sessão de novo até conseguir, com espera crescente.
+func (s *Sessao) reconectar() <-chan amqp.Delivery {
+	for tentativa := 1; ; tentativa++ {
+		msgs, err := s.abrir()
+		if err == nil {
+			fmt.Println("\nReconectado ao broker.")
+			return msgs
+		}
+		espera := s.backoff.espera(tentativa)
+		log.Printf("Falha ao reconectar (tentativa %d): %v; nova tentativa em %v", tentativa, err, espera)
+		time.Sleep(espera)
+	}
+}
+
+// Canal atual, usado para publicar.
+func (s *Sessao) canal() *amqp.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ch
+}
+
+// Fecha a conexão atual; o consumo termina e o laço de mensagens reconecta.
+func (s *Sessao) derrubar() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// Fecha canal e conexão antes de o cliente sair.
+func (s *Sessao) fechar() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch != nil {
+		s.ch.Close()
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// Derruba a sessão quando nenhum broadcast chega dentro do limite, para
+// que o laço de mensagens reconecte. Só vigia depois do primeiro
+// heartbeat: servidores sem HEARTBEAT_INTERVAL não o enviam.
+func vigiar(s *Sessao, contato *atomic.Int64, armado *atomic.Bool, limite time.Duration) {
+	t := time.NewTicker(limite / 4)
+	defer t.Stop()
+	for range t.C {
+		if !armado.Load() {
+			continue
+		}
+		silencio := time.Since(time.Unix(0, contato.Load()))
+		if silencio > limite {
+			fmt.Printf("\nContato com o servidor perdido: nenhuma mensagem há %v.\n", silencio.Round(time.Second))
+			contato.Store(time.Now().UnixNano())
+			s.derrubar()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Certificado do cliente para amqps:// com autenticação EXTERNAL
+// (rabbitmq_auth_mechanism_ssl). Com TLS_CERT e TLS_KEY, o broker
+// identifica o eleitor pelo certificado e o ID não é perguntado; o voto
+// segue com a propriedade user_id, que o broker confere com o usuário da
+// conexão e o servidor usa com VOTER_IDENTITY=cert.
+type Certificado struct {
+	tls *tls.Config
+	// Usuário da conexão: TLS_USER_ID ou, sem ele, o CN do certificado.
+	usuario string
+}
+
+// Lê TLS_CERT, TLS_KEY e TLS_CA (opcional, CA do broker). Devolve nil sem
+// TLS_CERT.
+func lerCertificado() (*Certificado, error) {
+	arqCert, arqChave := os.Getenv("TLS_CERT"), os.Getenv("TLS_KEY")
+	if arqCert == "" {
+		return nil, nil
+	}
+	if arqChave == "" {
+		return nil, fmt.Errorf("TLS_CERT sem TLS_KEY")
+	}
+	par, err := tls.LoadX509KeyPair(arqCert, arqChave)
+	if err != nil {
+		return nil, err
+	}
+	folha, err := x509.ParseCertificate(par.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{par}}
+	if arqCA := os.Getenv("TLS_CA"); arqCA != "" {
+		pem, err := os.ReadFile(arqCA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: nenhum certificado PEM", arqCA)
+		}
+	}
+
+	// O broker usa o CN por padrão (ssl_cert_login_from = common_name);
+	// com outro campo (ex.: SAN), TLS_USER_ID informa o nome que ele vê.
+	usuario := os.Getenv("TLS_USER_ID")
+	if usuario == "" {
+		usuario = folha.Subject.CommonName
+	}
+	if usuario == "" {
+		return nil, fmt.Errorf("certificado sem CN; defina TLS_USER_ID")
+	}
+	return &Certificado{tls: cfg, usuario: usuario}, nil
+}
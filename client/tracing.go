@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Rastreamento OpenTelemetry do envio do voto. O contexto do span vai
+// nos headers AMQP para que o servidor continue o mesmo trace. Sem
+// OTEL_EXPORTER_OTLP_ENDPOINT, o tracer global é um no-op.
+
+var tracer = otel.Tracer("votacao-rabbitmq/client")
+
+// Envia os spans pendentes e desliga o exportador; trocada por
+// iniciarTracing. Roda uma vez, na saída (ver sair).
+var finalizarTracing = func() {}
+
+// Encerra o processo depois de enviar os spans, fora do caminho do voto.
+func sair(codigo int) {
+	finalizarTracing()
+	os.Exit(codigo)
+}
+
+func iniciarTracing() {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return
+	}
+
+	exp, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		log.Printf("Tracing desabilitado: %v", err)
+		return
+	}
+
+	res, _ := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName("votacao-client")),
+		resource.Environment(),
+	)
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	finalizarTracing = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		tp.Shutdown(ctx)
+	}
+
+	// O cliente interativo costuma sair por CTRL+C, depois do voto: os
+	// spans ainda no batcher saem antes.
+	sinais := make(chan os.Signal, 1)
+	signal.Notify(sinais, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sinais
+		sair(130)
+	}()
+}
+
+// Adapta os headers AMQP ao TextMapCarrier do OpenTelemetry.
+type cabecalhosAMQP amqp.Table
+
+func (c cabecalhosAMQP) Get(chave string) string {
+	v, _ := c[chave].(string)
+	return v
+}
+
+func (c cabecalhosAMQP) Set(chave, valor string) {
+	c[chave] = valor
+}
+
+func (c cabecalhosAMQP) Keys() []string {
+	chaves := make([]string, 0, len(c))
+	for k := range c {
+		chaves = append(chaves, k)
+	}
+	return chaves
+}
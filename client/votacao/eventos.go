@@ -0,0 +1,358 @@
+package votacao
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Event é um broadcast do servidor já interpretado. As variantes são
+// PartialResult, FinalResult, Confirmation e Error; use um type switch.
+type Event interface {
+	evento()
+}
+
+// ResultadoOpcao é a contagem de uma opção.
+type ResultadoOpcao struct {
+	Opcao string
+	Votos int
+	// Fração do total de votos, de 0 a 100. Na apuração por aprovação, é
+	// a fração dos votantes que aprovaram a opção, e a soma pode passar
+	// de 100.
+	Percentual float64
+}
+
+// PartialResult é a contagem parcial da votação.
+type PartialResult struct {
+	// Opções da mais votada para a menos votada; empates em ordem alfabética.
+	Resultados []ResultadoOpcao
+	// Soma dos votos de todas as opções (votos de múltipla escolha contam
+	// uma vez por opção).
+	Total int
+	// Opções que atingiram o limite de vagas.
+	Fechadas []string
+	// Participação sobre a lista de eleitores (0 a 1), quando há lista.
+	Participacao float64
+}
+
+// FinalResult é o resultado final; depois dele o canal é fechado.
+type FinalResult struct {
+	Resultados []ResultadoOpcao
+	Total      int
+	// Opção vencedora, vazia em caso de empate sem política de desempate.
+	Vencedor string
+	Empate   bool
+	// Motivo do encerramento ("timeout", "admin", "idle", ...) e duração.
+	Motivo       string
+	Duracao      time.Duration
+	Participacao float64
+}
+
+// Confirmation confirma um voto contado pelo servidor.
+type Confirmation struct {
+	UserID string
+	Opcoes []string
+	// Opções substituídas, num revoto.
+	Anteriores []string
+	Mensagem   string
+}
+
+// Error é a recusa de um voto pelo servidor.
+type Error struct {
+	UserID   string
+	Codigo   string
+	Mensagem string
+}
+
+func (e *Error) Error() string { return e.Codigo + ": " + e.Mensagem }
+
+func (PartialResult) evento() {}
+func (FinalResult) evento()   {}
+func (Confirmation) evento()  {}
+func (*Error) evento()        {}
+
+// Campos do broadcast usados pelos eventos.
+type mensagem struct {
+	Tipo            string         `json:"tipo"`
+	UserID          string         `json:"userId"`
+	Codigo          string         `json:"codigo"`
+	Mensagem        string         `json:"mensagem"`
+	Result          map[string]int `json:"resultado"`
+	Seq             int64          `json:"seq"`
+	Delta           map[string]int `json:"delta"`
+	Completa        bool           `json:"completa"`
+	Fechadas        []string       `json:"fechadas"`
+	Participacao    float64        `json:"participacao"`
+	Opcao           string         `json:"opcao"`
+	Anterior        string         `json:"anterior"`
+	Vencedor        string         `json:"vencedor"`
+	DuracaoSegundos int            `json:"duracaoSegundos"`
+	Motivo          string         `json:"motivo"`
+	Apuracao        string         `json:"apuracao"`
+	Votantes        int            `json:"votantes"`
+}
+
+// Base dos percentuais: os votantes na aprovação; 0 usa o total de votos.
+func (m mensagem) basePercentual() int {
+	if m.Apuracao == "approval" {
+		return m.Votantes
+	}
+	return 0
+}
+
+// Subscribe passa a receber os broadcasts da votação numa fila exclusiva
+// e os entrega como eventos. O canal é fechado depois do FinalResult, ao
+// cancelar o ctx ou no Close do cliente.
+//
+// Se a conexão cair, a biblioteca reconecta e assina de novo numa fila
+// nova; os broadcasts enviados durante a queda se perdem, e a contagem
+// volta com a próxima parcial completa, pedida logo após a reconexão.
+//
+// No modo delta do servidor (PARTIAL_DELTAS) a contagem é acumulada aqui;
+// ao perder um delta, a biblioteca pede uma parcial completa e não emite
+// parciais até recebê-la.
+func (c *Cliente) Subscribe(ctx context.Context) (<-chan Event, error) {
+	fechar, msgs, err := c.assinar()
+	if err != nil {
+		return nil, err
+	}
+
+	eventos := make(chan Event, 64)
+	go func() {
+		defer close(eventos)
+		// Sem fechar quando a reassinatura falhou.
+		defer func() {
+			if fechar != nil {
+				fechar()
+			}
+		}()
+
+		var contagem map[string]int
+		var seq int64
+		pedido := false
+		for {
+			var m amqp.Delivery
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok = <-msgs:
+				if !ok {
+					fechar()
+					if fechar, msgs, err = c.reassinar(ctx); err != nil {
+						return
+					}
+					contagem = nil
+					c.publicar(ctx, "snapshot", amqp.Publishing{})
+					pedido = true
+					continue
+				}
+			}
+
+			msg, err := decodificar(m)
+			if err != nil {
+				continue
+			}
+
+			var ev Event
+			switch msg.Tipo {
+			case "parcial":
+				switch {
+				case msg.Seq == 0 || msg.Completa:
+					contagem, seq, pedido = msg.Result, msg.Seq, false
+					if contagem == nil {
+						contagem = map[string]int{}
+					}
+				case contagem != nil && msg.Seq == seq+1:
+					for op, d := range msg.Delta {
+						contagem[op] += d
+					}
+					seq = msg.Seq
+				case contagem != nil && msg.Seq <= seq:
+					// Já incluído numa parcial completa mais nova.
+					continue
+				default:
+					// Delta fora da sequência: espera a próxima completa.
+					if !pedido {
+						c.publicar(ctx, "snapshot", amqp.Publishing{})
+						pedido = true
+					}
+					contagem = nil
+					continue
+				}
+				resultados, total := ordenar(contagem, msg.basePercentual())
+				ev = PartialResult{
+					Resultados:   resultados,
+					Total:        total,
+					Fechadas:     msg.Fechadas,
+					Participacao: msg.Participacao,
+				}
+			case "final":
+				resultados, total := ordenar(msg.Result, msg.basePercentual())
+				final := FinalResult{
+					Resultados:   resultados,
+					Total:        total,
+					Motivo:       msg.Motivo,
+					Duracao:      time.Duration(msg.DuracaoSegundos) * time.Second,
+					Participacao: msg.Participacao,
+				}
+				if msg.Vencedor == "empate" {
+					final.Empate = true
+				} else {
+					final.Vencedor = msg.Vencedor
+				}
+				ev = final
+			case "confirmacao":
+				ev = Confirmation{
+					UserID:     msg.UserID,
+					Opcoes:     separar(msg.Opcao),
+					Anteriores: separar(msg.Anterior),
+					Mensagem:   msg.Mensagem,
+				}
+			case "erro":
+				ev = &Error{UserID: msg.UserID, Codigo: msg.Codigo, Mensagem: msg.Mensagem}
+			default:
+				continue
+			}
+
+			select {
+			case eventos <- ev:
+			case <-ctx.Done():
+				return
+			}
+			if msg.Tipo == "final" {
+				return
+			}
+		}
+	}()
+	return eventos, nil
+}
+
+// Abre o canal do Subscribe numa fila exclusiva ligada ao broadcast e
+// devolve a função que o fecha. Com NovoClienteTransporte, consome a fila
+// do chamador, e fechá-la fica com o dono do transporte.
+func (c *Cliente) assinar() (func(), <-chan amqp.Delivery, error) {
+	if c.t != nil {
+		c.mu.Lock()
+		fechado := c.fechado
+		c.mu.Unlock()
+		if fechado {
+			return nil, nil, ErrFechado
+		}
+		msgs, err := c.t.Consume(c.fila, "", true, true, false, false, nil)
+		return func() {}, msgs, err
+	}
+
+	conn, err := c.conexao()
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, nil, err
+	}
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	// Rota sem idioma e a do idioma do cliente (BROADCAST_LANGS); num
+	// fanout as rotas são ignoradas.
+	for _, rota := range []string{"broadcast", "broadcast." + c.idioma} {
+		if err == nil {
+			err = ch.QueueBind(q.Name, rota, c.prefixo+".broadcast", false, nil)
+		}
+	}
+	var msgs <-chan amqp.Delivery
+	if err == nil {
+		msgs, err = ch.Consume(q.Name, "", true, true, false, false, nil)
+	}
+	if err != nil {
+		ch.Close()
+		return nil, nil, err
+	}
+	return func() { ch.Close() }, msgs, nil
+}
+
+// Assina de novo depois de uma queda, com espera crescente entre as
+// tentativas (1s, dobrando até 30s). Desiste no Close do cliente, ao
+// cancelar o ctx, quando a conexão é de NovoCliente e caiu ou quando a
+// fila do transporte de NovoClienteTransporte foi fechada.
+func (c *Cliente) reassinar(ctx context.Context) (func(), <-chan amqp.Delivery, error) {
+	if c.t != nil {
+		return nil, nil, amqp.ErrClosed
+	}
+	espera := time.Second
+	for {
+		ch, msgs, err := c.assinar()
+		if err == nil || errors.Is(err, ErrFechado) || errors.Is(err, amqp.ErrClosed) && len(c.urls) == 0 {
+			return ch, msgs, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(espera):
+		}
+		espera = min(espera*2, 30*time.Second)
+	}
+}
+
+// Decodifica o broadcast pelo ContentEncoding e ContentType.
+func decodificar(m amqp.Delivery) (mensagem, error) {
+	var msg mensagem
+	body := m.Body
+	if m.ContentEncoding == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return msg, err
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return msg, err
+		}
+	}
+	if m.ContentType == "application/msgpack" {
+		dec := msgpack.NewDecoder(bytes.NewReader(body))
+		dec.SetCustomStructTag("json")
+		return msg, dec.Decode(&msg)
+	}
+	return msg, json.Unmarshal(body, &msg)
+}
+
+// Contagem em ordem decrescente de votos, com o total e os percentuais
+// sobre base (ou sobre o total, com base 0).
+func ordenar(contagem map[string]int, base int) ([]ResultadoOpcao, int) {
+	total := 0
+	resultados := make([]ResultadoOpcao, 0, len(contagem))
+	for op, n := range contagem {
+		total += n
+		resultados = append(resultados, ResultadoOpcao{Opcao: op, Votos: n})
+	}
+	sort.Slice(resultados, func(i, j int) bool {
+		if resultados[i].Votos != resultados[j].Votos {
+			return resultados[i].Votos > resultados[j].Votos
+		}
+		return resultados[i].Opcao < resultados[j].Opcao
+	})
+	if base == 0 {
+		base = total
+	}
+	if base > 0 {
+		for i := range resultados {
+			resultados[i].Percentual = float64(resultados[i].Votos) * 100 / float64(base)
+		}
+	}
+	return resultados, total
+}
+
+// Opções separadas por vírgula; nil quando vazio.
+func separar(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,183 @@
+package votacao
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Transporte de teste: entrega o que o teste põe em fila e guarda as
+// publicações do cliente. falhar, quando definida, decide o erro de cada
+// publicação.
+type transporteTeste struct {
+	fila chan amqp.Delivery
+
+	mu         sync.Mutex
+	publicados []publicado
+	falhar     func(chave string, p amqp.Publishing) error
+}
+
+type publicado struct {
+	chave string
+	msg   amqp.Publishing
+}
+
+func novoTransporteTeste() *transporteTeste {
+	return &transporteTeste{fila: make(chan amqp.Delivery, 64)}
+}
+
+func (t *transporteTeste) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.falhar != nil {
+		if err := t.falhar(key, msg); err != nil {
+			return err
+		}
+	}
+	t.publicados = append(t.publicados, publicado{key, msg})
+	return nil
+}
+
+func (t *transporteTeste) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	return t.fila, nil
+}
+
+// Publicações do cliente com a routing key dada.
+func (t *transporteTeste) comChave(chave string) []amqp.Publishing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var msgs []amqp.Publishing
+	for _, p := range t.publicados {
+		if p.chave == chave {
+			msgs = append(msgs, p.msg)
+		}
+	}
+	return msgs
+}
+
+// Põe um broadcast do servidor na fila do Subscribe.
+func (t *transporteTeste) broadcast(msg map[string]any) {
+	body, _ := json.Marshal(msg)
+	t.fila <- amqp.Delivery{ContentType: "application/json", Body: body}
+}
+
+func TestOrdenar(t *testing.T) {
+	resultados, total := ordenar(map[string]int{"B": 2, "A": 2, "C": 4}, 0)
+	if total != 8 {
+		t.Errorf("total = %d, esperado 8", total)
+	}
+	ordem := make([]string, len(resultados))
+	for i, r := range resultados {
+		ordem[i] = r.Opcao
+	}
+	// Empates em ordem alfabética.
+	if !slices.Equal(ordem, []string{"C", "A", "B"}) {
+		t.Errorf("ordem = %v, esperado [C A B]", ordem)
+	}
+	if resultados[0].Percentual != 50 || resultados[1].Percentual != 25 {
+		t.Errorf("percentuais = %+v, esperado C:50 A:25", resultados)
+	}
+
+	// Na aprovação, a base são os votantes e a soma passa de 100.
+	resultados, _ = ordenar(map[string]int{"A": 3, "B": 2}, 4)
+	if resultados[0].Percentual != 75 || resultados[1].Percentual != 50 {
+		t.Errorf("percentuais sobre 4 votantes = %+v, esperado A:75 B:50", resultados)
+	}
+
+	// Sem votos, sem divisão por zero.
+	if resultados, total := ordenar(map[string]int{"A": 0}, 0); total != 0 || resultados[0].Percentual != 0 {
+		t.Errorf("contagem vazia = %+v (total %d)", resultados, total)
+	}
+}
+
+// Deltas aplicados em sequência, repetidos ignorados e, depois de um
+// buraco, um pedido de parcial completa e nenhuma parcial até ela chegar.
+func TestSubscribeAcumulaDeltas(t *testing.T) {
+	tr := novoTransporteTeste()
+	c := NovoClienteTransporte(tr, "", "cliente")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	eventos, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr.broadcast(map[string]any{"tipo": "parcial", "seq": 1, "completa": true, "resultado": map[string]int{"A": 1}})
+	tr.broadcast(map[string]any{"tipo": "parcial", "seq": 2, "delta": map[string]int{"A": 1, "B": 1}})
+	tr.broadcast(map[string]any{"tipo": "parcial", "seq": 2, "delta": map[string]int{"A": 1, "B": 1}})
+	tr.broadcast(map[string]any{"tipo": "parcial", "seq": 4, "delta": map[string]int{"A": 1}})
+	tr.broadcast(map[string]any{"tipo": "parcial", "seq": 5, "delta": map[string]int{"B": 1}})
+	tr.broadcast(map[string]any{"tipo": "heartbeat"})
+	tr.broadcast(map[string]any{"tipo": "parcial", "seq": 5, "completa": true, "resultado": map[string]int{"A": 3, "B": 2}})
+	tr.broadcast(map[string]any{"tipo": "final", "resultado": map[string]int{"A": 3, "B": 2}, "vencedor": "A"})
+
+	var contagens []map[string]int
+	var final *FinalResult
+	for ev := range eventos {
+		switch e := ev.(type) {
+		case PartialResult:
+			contagem := map[string]int{}
+			for _, r := range e.Resultados {
+				contagem[r.Opcao] = r.Votos
+			}
+			contagens = append(contagens, contagem)
+		case FinalResult:
+			final = &e
+		}
+	}
+
+	esperadas := []map[string]int{{"A": 1}, {"A": 2, "B": 1}, {"A": 3, "B": 2}}
+	if len(contagens) != len(esperadas) {
+		t.Fatalf("parciais = %v, esperadas %v", contagens, esperadas)
+	}
+	for i := range esperadas {
+		for op, n := range esperadas[i] {
+			if contagens[i][op] != n {
+				t.Errorf("parcial %d = %v, esperada %v", i, contagens[i], esperadas[i])
+			}
+		}
+	}
+	if n := len(tr.comChave("snapshot")); n != 1 {
+		t.Errorf("pedidos de parcial completa = %d, esperado 1", n)
+	}
+	if final == nil || final.Vencedor != "A" || final.Total != 5 {
+		t.Errorf("final = %+v, esperado vencedor A com 5 votos", final)
+	}
+}
+
+// Com NovoClienteTransporte não há reassinatura: a fila fechada pelo dono
+// do transporte encerra o canal de eventos.
+func TestSubscribeTerminaComAFila(t *testing.T) {
+	tr := novoTransporteTeste()
+	c := NovoClienteTransporte(tr, "", "cliente")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	eventos, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr.broadcast(map[string]any{"tipo": "erro", "userId": "ana", "codigo": "ALREADY_VOTED", "mensagem": "Você já votou."})
+	close(tr.fila)
+
+	ev, ok := <-eventos
+	if e, isErro := ev.(*Error); !ok || !isErro || e.Codigo != "ALREADY_VOTED" {
+		t.Errorf("evento = %+v, esperado o erro ALREADY_VOTED", ev)
+	}
+	select {
+	case ev, ok := <-eventos:
+		if ok {
+			t.Errorf("evento inesperado depois da fila fechada: %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("canal de eventos não fechou com a fila")
+	}
+}
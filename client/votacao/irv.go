@@ -0,0 +1,88 @@
+package votacao
+
+import "sort"
+
+// RodadaIRV é uma rodada da apuração por ranking (TALLY=irv), como vem
+// no "final" do servidor.
+type RodadaIRV struct {
+	Rodada   int            `json:"rodada"`
+	Contagem map[string]int `json:"contagem"`
+	// Opções eliminadas ao fim da rodada; vazio na rodada que decide.
+	Eliminadas []string `json:"eliminadas,omitempty"`
+	// Cédulas sem nenhuma opção restante, acumuladas até esta rodada.
+	Esgotadas int `json:"esgotadas,omitempty"`
+}
+
+// RodadasIRV apura as rodadas do segundo turno instantâneo com as regras
+// do servidor, para quem recontar a votação fora dele (ex.: o replay do
+// VOTE_LOG). Cada cédula é um ranking, da opção preferida para a menos
+// preferida; opcoes são as que estão na disputa desde o início, além das
+// que aparecem nas cédulas.
+//
+// Cada rodada conta cada cédula para a opção mais bem colocada ainda na
+// disputa. A apuração para quando uma opção tem mais da metade das
+// cédulas ativas, quando só resta uma ou quando todas as restantes
+// empatam; senão, as empatadas em último saem juntas. A última rodada é a
+// que decide: o vencedor é a mais votada nela, com o desempate do
+// chamador quando há empate.
+func RodadasIRV(cedulas [][]string, opcoes []string) []RodadaIRV {
+	disputa := map[string]bool{}
+	for _, op := range opcoes {
+		disputa[op] = true
+	}
+	for _, c := range cedulas {
+		for _, op := range c {
+			disputa[op] = true
+		}
+	}
+
+	var rodadas []RodadaIRV
+	for n := 1; len(disputa) > 0; n++ {
+		rodada := RodadaIRV{Rodada: n, Contagem: map[string]int{}}
+		for op := range disputa {
+			rodada.Contagem[op] = 0
+		}
+		ativas := 0
+		for _, c := range cedulas {
+			if op, ok := preferida(c, disputa); ok {
+				rodada.Contagem[op]++
+				ativas++
+			} else {
+				rodada.Esgotadas++
+			}
+		}
+
+		menor, maior := -1, 0
+		for _, votos := range rodada.Contagem {
+			if menor < 0 || votos < menor {
+				menor = votos
+			}
+			maior = max(maior, votos)
+		}
+
+		// Maioria das cédulas ativas, uma só opção ou empate geral: decide.
+		if maior*2 > ativas || len(disputa) == 1 || menor == maior {
+			return append(rodadas, rodada)
+		}
+
+		for op, votos := range rodada.Contagem {
+			if votos == menor {
+				rodada.Eliminadas = append(rodada.Eliminadas, op)
+				delete(disputa, op)
+			}
+		}
+		sort.Strings(rodada.Eliminadas)
+		rodadas = append(rodadas, rodada)
+	}
+	return rodadas
+}
+
+// Opção mais bem colocada da cédula que ainda está na disputa.
+func preferida(cedula []string, disputa map[string]bool) (string, bool) {
+	for _, op := range cedula {
+		if disputa[op] {
+			return op, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,172 @@
+package votacao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Erros individuais devolvidos em ErroLote.
+var (
+	// O broker recusou a mensagem (basic.nack).
+	ErrRecusado = errors.New("voto recusado pelo broker")
+	// Nenhuma fila ligada à exchange de votos: o servidor não receberia.
+	ErrSemRota = errors.New("voto sem rota: nenhuma fila ligada à exchange de votos")
+)
+
+// ErroLote informa quais votos de um VoteBatch não foram confirmados.
+// Erros está na mesma ordem dos votos; nil significa voto confirmado.
+type ErroLote struct {
+	Erros []error
+}
+
+func (e *ErroLote) Error() string {
+	falhas := 0
+	var primeiro error
+	for _, err := range e.Erros {
+		if err != nil {
+			if primeiro == nil {
+				primeiro = err
+			}
+			falhas++
+		}
+	}
+	return fmt.Sprintf("%d de %d votos não confirmados: %v", falhas, len(e.Erros), primeiro)
+}
+
+// Permite errors.Is(err, context.Canceled) e afins sobre o lote.
+func (e *ErroLote) Unwrap() []error {
+	var erros []error
+	for _, err := range e.Erros {
+		if err != nil {
+			erros = append(erros, err)
+		}
+	}
+	return erros
+}
+
+// VoteBatch publica os votos num único canal com publisher confirms e
+// espera a confirmação do broker para cada um. Retorna nil quando todos
+// foram confirmados, ou um *ErroLote com o resultado de cada voto.
+//
+// A confirmação do broker indica que o voto chegou à fila, não que foi
+// contado: a validação continua com o servidor. Se o ctx for cancelado
+// no meio do lote, os votos ainda não publicados nem confirmados recebem
+// o erro do contexto; os já confirmados continuam valendo.
+func (c *Cliente) VoteBatch(ctx context.Context, votos []Voto) error {
+	if len(votos) == 0 {
+		return nil
+	}
+
+	// Sem broker (NovoClienteTransporte) não há confirms nem devoluções:
+	// cada voto vale como confirmado quando o transporte o aceita.
+	if c.t != nil {
+		erros := make([]error, len(votos))
+		falhou := false
+		for i, v := range votos {
+			if erros[i] = c.Vote(ctx, v); erros[i] != nil {
+				falhou = true
+			}
+		}
+		if falhou {
+			return &ErroLote{Erros: erros}
+		}
+		return nil
+	}
+
+	// Canal próprio do lote: os confirms e as devoluções ficam isolados
+	// de outros lotes em andamento.
+	conn, err := c.conexao()
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return err
+	}
+	// O broker envia o basic.return antes do ack da mesma mensagem, então
+	// ao fim das confirmações todas as devoluções já estão no buffer.
+	devolvidas := ch.NotifyReturn(make(chan amqp.Return, len(votos)))
+
+	erros := make([]error, len(votos))
+	pendentes := make([]*amqp.DeferredConfirmation, len(votos))
+	posicao := make(map[string]int, len(votos))
+
+	for i, v := range votos {
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(votos); j++ {
+				erros[j] = err
+			}
+			break
+		}
+
+		if v.Nonce == "" {
+			v.Nonce = NovoNonce()
+		}
+		if v.Lang == "" {
+			v.Lang = c.idioma
+		}
+		body, _ := json.Marshal(v)
+
+		dc, err := ch.PublishWithDeferredConfirmWithContext(
+			ctx,
+			c.exchange,
+			"voto",
+			true, // mandatory: sem fila ligada, o broker devolve o voto.
+			false,
+			amqp.Publishing{
+				ContentType: "application/json",
+				Priority:    v.Prioridade,
+				MessageId:   v.Nonce,
+				Headers:     cabecalhosTrace(ctx),
+				Body:        body,
+			},
+		)
+		if err != nil {
+			erros[i] = err
+			continue
+		}
+		pendentes[i] = dc
+		posicao[v.Nonce] = i
+	}
+
+	for i, dc := range pendentes {
+		if dc == nil {
+			continue
+		}
+		ok, err := dc.WaitContext(ctx)
+		switch {
+		case err != nil:
+			erros[i] = err
+		case !ok:
+			erros[i] = ErrRecusado
+		}
+	}
+
+drenar:
+	for {
+		select {
+		case r := <-devolvidas:
+			if i, ok := posicao[r.MessageId]; ok && erros[i] == nil {
+				erros[i] = fmt.Errorf("%w (%d %s)", ErrSemRota, r.ReplyCode, r.ReplyText)
+			}
+		default:
+			break drenar
+		}
+	}
+
+	for _, err := range erros {
+		if err != nil {
+			return &ErroLote{Erros: erros}
+		}
+	}
+	return nil
+}
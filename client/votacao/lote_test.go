@@ -0,0 +1,92 @@
+package votacao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestErroLote(t *testing.T) {
+	semRota := fmt.Errorf("%w (312 NO_ROUTE)", ErrSemRota)
+	err := error(&ErroLote{Erros: []error{nil, semRota, context.Canceled}})
+
+	if msg := err.Error(); !strings.HasPrefix(msg, "2 de 3 votos não confirmados: ") || !strings.Contains(msg, "312 NO_ROUTE") {
+		t.Errorf("mensagem = %q, esperadas as 2 falhas e a primeira delas", msg)
+	}
+	if !errors.Is(err, ErrSemRota) || !errors.Is(err, context.Canceled) {
+		t.Errorf("errors.Is não encontra os erros do lote: %v", err)
+	}
+	if errors.Is(err, ErrRecusado) {
+		t.Errorf("errors.Is(%v, ErrRecusado) = true, sem voto recusado", err)
+	}
+	var lote *ErroLote
+	if !errors.As(err, &lote) || lote.Erros[0] != nil {
+		t.Errorf("errors.As = %+v, esperado o primeiro voto confirmado", lote)
+	}
+}
+
+// O lote informa o erro de cada voto, na ordem, e preenche nonce e idioma.
+func TestVoteBatchPorVoto(t *testing.T) {
+	tr := novoTransporteTeste()
+	tr.falhar = func(chave string, p amqp.Publishing) error {
+		var v Voto
+		json.Unmarshal(p.Body, &v)
+		if v.UserID == "bruno" {
+			return amqp.ErrClosed
+		}
+		return nil
+	}
+	c := NovoClienteTransporte(tr, "", "cliente")
+
+	err := c.VoteBatch(context.Background(), []Voto{
+		{UserID: "ana", Option: "A"},
+		{UserID: "bruno", Option: "B"},
+		{UserID: "carla", Option: "A", Lang: "en"},
+	})
+	var lote *ErroLote
+	if !errors.As(err, &lote) {
+		t.Fatalf("err = %v, esperado *ErroLote", err)
+	}
+	if lote.Erros[0] != nil || !errors.Is(lote.Erros[1], amqp.ErrClosed) || lote.Erros[2] != nil {
+		t.Errorf("erros = %v, esperada só a falha de bruno", lote.Erros)
+	}
+
+	publicados := tr.comChave("voto")
+	if len(publicados) != 2 {
+		t.Fatalf("votos publicados = %d, esperados 2", len(publicados))
+	}
+	for i, lang := range []string{idiomaPadrao, "en"} {
+		var v Voto
+		json.Unmarshal(publicados[i].Body, &v)
+		if v.Nonce == "" || publicados[i].MessageId != v.Nonce {
+			t.Errorf("voto %d sem nonce no corpo e no MessageId: %+v", i, publicados[i])
+		}
+		if v.Lang != lang {
+			t.Errorf("idioma do voto %d = %q, esperado %q", i, v.Lang, lang)
+		}
+	}
+}
+
+// Com o ctx cancelado, nenhum voto sai e todos levam o erro do contexto.
+func TestVoteBatchCancelado(t *testing.T) {
+	tr := novoTransporteTeste()
+	c := NovoClienteTransporte(tr, "", "cliente")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := c.VoteBatch(ctx, []Voto{{UserID: "ana", Option: "A"}, {UserID: "bruno", Option: "B"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, esperado context.Canceled", err)
+	}
+	if n := len(tr.comChave("voto")); n != 0 {
+		t.Errorf("votos publicados = %d com o ctx cancelado", n)
+	}
+	if err := c.VoteBatch(ctx, nil); err != nil {
+		t.Errorf("lote vazio: %v", err)
+	}
+}
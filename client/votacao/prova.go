@@ -0,0 +1,45 @@
+package votacao
+
+import (
+	"crypto/sha256"
+	"math/bits"
+	"strconv"
+)
+
+// ProvaDeTrabalho calcula o campo Pow de um voto para um servidor com
+// POW_DIFFICULTY: um texto tal que sha256(userID + ":" + pow) comece com
+// dificuldade bits zero. userID é a chave que o servidor conta:
+// "cedula:<hash>" em votos anônimos. Cada bit a mais dobra o tempo médio.
+func ProvaDeTrabalho(userID string, dificuldade int) string {
+	for n := uint64(0); ; n++ {
+		pow := strconv.FormatUint(n, 36)
+		if ProvaValida(userID, pow, dificuldade) {
+			return pow
+		}
+	}
+}
+
+// ProvaValida informa se pow atinge a dificuldade para userID; é a mesma
+// conferência que o servidor faz em cada voto. Dificuldade 0 aceita
+// qualquer voto, e um pow vazio só vale nela.
+func ProvaValida(userID, pow string, dificuldade int) bool {
+	if dificuldade == 0 {
+		return true
+	}
+	if pow == "" {
+		return false
+	}
+	return zerosIniciais(sha256.Sum256([]byte(userID+":"+pow))) >= dificuldade
+}
+
+// Bits zero no início do hash.
+func zerosIniciais(hash [sha256.Size]byte) int {
+	n := 0
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,22 @@
+package votacao
+
+import "testing"
+
+// A prova resolvida pela biblioteca passa na conferência do servidor e
+// não serve para outro usuário.
+func TestProvaDeTrabalho(t *testing.T) {
+	const dificuldade = 12
+	pow := ProvaDeTrabalho("ana", dificuldade)
+	if !ProvaValida("ana", pow, dificuldade) {
+		t.Fatalf("prova %q de ana recusada", pow)
+	}
+	if ProvaValida("bruno", pow, dificuldade) && ProvaValida("carla", pow, dificuldade) {
+		t.Errorf("prova %q de ana vale para bruno e carla", pow)
+	}
+	if ProvaValida("ana", "", dificuldade) {
+		t.Error("pow vazio aceito com dificuldade")
+	}
+	if !ProvaValida("ana", "", 0) {
+		t.Error("dificuldade 0 recusou um voto sem pow")
+	}
+}
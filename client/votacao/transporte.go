@@ -0,0 +1,29 @@
+package votacao
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Transport cobre a publicação e o consumo de um Cliente criado por
+// NovoClienteTransporte, com as mesmas assinaturas do *amqp.Channel. É a
+// mesma interface do Transport do servidor, então o transporte em memória
+// dele roda cliente e servidor juntos num teste, sem RabbitMQ.
+type Transport interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+}
+
+var _ Transport = (*amqp.Channel)(nil)
+
+// NovoClienteTransporte publica e consome por t, sem conexão própria nem
+// reconexão. fila é a fila do Subscribe, já ligada pelo chamador à
+// exchange de broadcast. Sem publisher confirms, Vote e VoteBatch voltam
+// assim que t aceita a publicação. Prefixo vazio usa "votacao".
+func NovoClienteTransporte(t Transport, prefixo, fila string) *Cliente {
+	if prefixo == "" {
+		prefixo = "votacao"
+	}
+	return &Cliente{t: t, fila: fila, prefixo: prefixo, exchange: prefixo + ".votos", idioma: idiomaPadrao}
+}
@@ -0,0 +1,3 @@
+module votacao-rabbitmq/merge
+
+go 1.22
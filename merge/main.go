@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+//
+// Junta os resultados de vários servidores independentes (votos
+// distribuídos entre instâncias, cada uma com a própria contagem).
+//
+// Só com os finais, a soma conta em dobro quem votou em mais de uma
+// instância. Com os mapas de auditoria (admin audit), a contagem é refeita
+// a partir dos votos, um por usuário; os finais servem então para
+// conferência.
+//
+
+// Lista de arquivos de uma flag repetível.
+type arquivos []string
+
+func (a *arquivos) String() string     { return strings.Join(*a, ",") }
+func (a *arquivos) Set(v string) error { *a = append(*a, v); return nil }
+
+// Campos usados de um resultado: o "final" do broadcast, a saída do
+// "admin export" em JSON e o LIVE_RESULTS_FILE têm todos "resultado".
+type resultadoServidor struct {
+	Resultado map[string]int `json:"resultado"`
+}
+
+// Contagem combinada.
+type Combinado struct {
+	Resultado map[string]int `json:"resultado"`
+	// Votantes distintos, com auditoria.
+	Votos int `json:"votos,omitempty"`
+	// Usuários presentes em mais de um mapa, contados uma vez.
+	Duplicados int `json:"duplicados,omitempty"`
+	// Duplicados com escolhas diferentes entre instâncias; vale a do
+	// primeiro mapa informado.
+	Conflitos []string `json:"conflitos,omitempty"`
+	// Soma simples dos finais, para comparar com a contagem deduplicada.
+	SomaFinais map[string]int `json:"somaFinais,omitempty"`
+}
+
+func main() {
+	var finais, auditorias arquivos
+	flag.Var(&finais, "final", "resultado final em JSON de um servidor (repetível)")
+	flag.Var(&auditorias, "audit", "mapa de auditoria usuário -> opções de um servidor (repetível)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: merge [-final arquivo]... [-audit arquivo]...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(finais) == 0 && len(auditorias) == 0 || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var c Combinado
+	if len(finais) > 0 {
+		soma, err := somarFinais(finais)
+		if err != nil {
+			log.Fatalf("Erro ao ler os finais: %v", err)
+		}
+		c.SomaFinais = soma
+	}
+	if len(auditorias) > 0 {
+		mapas := make([]map[string]string, len(auditorias))
+		for i, caminho := range auditorias {
+			m, err := lerAuditoria(caminho)
+			if err != nil {
+				log.Fatalf("Erro ao ler a auditoria: %v", err)
+			}
+			mapas[i] = m
+		}
+		c = combinarAuditorias(mapas, auditorias, c.SomaFinais)
+	} else {
+		log.Println("Sem mapas de auditoria (-audit): usuários que votaram em mais de uma instância contam mais de uma vez.")
+		c.Resultado, c.SomaFinais = c.SomaFinais, nil
+	}
+
+	out, _ := json.MarshalIndent(c, "", "  ")
+	fmt.Println(string(out))
+}
+
+// Soma a contagem por opção de cada arquivo.
+func somarFinais(caminhos []string) (map[string]int, error) {
+	soma := map[string]int{}
+	for _, caminho := range caminhos {
+		dados, err := os.ReadFile(caminho)
+		if err != nil {
+			return nil, err
+		}
+		var r resultadoServidor
+		if err := json.Unmarshal(dados, &r); err != nil {
+			return nil, fmt.Errorf("%s: %w", caminho, err)
+		}
+		if r.Resultado == nil {
+			return nil, fmt.Errorf("%s: sem o campo \"resultado\"", caminho)
+		}
+		for op, n := range r.Resultado {
+			soma[op] += n
+		}
+	}
+	return soma, nil
+}
+
+// Lê um mapa usuário -> opções separadas por vírgula, como o gravado pelo
+// "audit -arquivo" ou impresso pelo admin.
+func lerAuditoria(caminho string) (map[string]string, error) {
+	dados, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]string
+	if err := json.Unmarshal(dados, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", caminho, err)
+	}
+	return m, nil
+}
+
+// Recontagem com um voto por usuário: na primeira instância em que ele
+// aparece. As opções da soma dos finais entram zeradas, para que opções
+// sem votos continuem no resultado.
+func combinarAuditorias(mapas []map[string]string, nomes []string, somaFinais map[string]int) Combinado {
+	c := Combinado{Resultado: map[string]int{}, SomaFinais: somaFinais}
+	for op := range somaFinais {
+		c.Resultado[op] = 0
+	}
+
+	escolha := map[string]string{}
+	origem := map[string]int{}
+	for i, m := range mapas {
+		for user, opcoes := range m {
+			anterior, visto := escolha[user]
+			if !visto {
+				escolha[user], origem[user] = opcoes, i
+				continue
+			}
+			c.Duplicados++
+			if anterior != opcoes {
+				c.Conflitos = append(c.Conflitos, fmt.Sprintf("%s: %s (%s) x %s (%s)", user, anterior, nomes[origem[user]], opcoes, nomes[i]))
+			}
+		}
+	}
+	sort.Strings(c.Conflitos)
+
+	for _, opcoes := range escolha {
+		for _, op := range strings.Split(opcoes, ",") {
+			c.Resultado[op]++
+		}
+	}
+	c.Votos = len(escolha)
+	return c
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"votacao-rabbitmq/client/votacao"
+)
+
+// Linha do log de votos gravado pelo servidor (VOTE_LOG).
+type RegistroVoto struct {
+	UserID    string   `json:"userId,omitempty"`
+	Opcoes    []string `json:"opcoes,omitempty"`
+	Peso      int      `json:"peso,omitempty"`
+	Nonce     string   `json:"nonce,omitempty"`
+	Timestamp string   `json:"timestamp"`
+	Reset     bool     `json:"reset,omitempty"`
+}
+
+// Resultado reconstruído a partir do log.
+type Recontagem struct {
+	Resultado  map[string]int `json:"resultado"`
+	Votos      int            `json:"votos"`
+	Rejeitados map[string]int `json:"rejeitados,omitempty"`
+
+	// Só com -tally irv: o vencedor das rodadas e as rodadas, como no
+	// "final" do servidor.
+	Vencedor string              `json:"vencedor,omitempty"`
+	Rodadas  []votacao.RodadaIRV `json:"rodadas,omitempty"`
+}
+
+// Modos de apuração, como no TALLY do servidor.
+const (
+	apuracaoMaioria   = "plurality"
+	apuracaoAprovacao = "approval"
+	apuracaoRanking   = "irv"
+)
+
+// Opções aceitas: lista fixa ("A,B,C") ou intervalo ("RANGE:1-10"),
+// no mesmo formato do VOTING_OPTIONS do servidor.
+type opcoesValidas struct {
+	lista    []string
+	min, max int
+}
+
+func lerOpcoes(spec string) (*opcoesValidas, error) {
+	if r, ok := strings.CutPrefix(spec, "RANGE:"); ok {
+		a, b, ok := strings.Cut(r, "-")
+		min, err1 := strconv.Atoi(a)
+		max, err2 := strconv.Atoi(b)
+		if !ok || err1 != nil || err2 != nil || min > max {
+			return nil, fmt.Errorf("intervalo inválido: %q", spec)
+		}
+		return &opcoesValidas{min: min, max: max}, nil
+	}
+	o := &opcoesValidas{}
+	for _, op := range strings.Split(spec, ",") {
+		if op = strings.TrimSpace(op); op != "" {
+			o.lista = append(o.lista, op)
+		}
+	}
+	if len(o.lista) == 0 {
+		return nil, fmt.Errorf("nenhuma opção em %q", spec)
+	}
+	return o, nil
+}
+
+func (o *opcoesValidas) valida(op string) bool {
+	if o.lista == nil {
+		n, err := strconv.Atoi(op)
+		return err == nil && strconv.Itoa(n) == op && n >= o.min && n <= o.max
+	}
+	for _, p := range o.lista {
+		if p == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Quantidade de opções: o maior voto possível na aprovação e no ranking.
+func (o *opcoesValidas) total() int {
+	if o.lista == nil {
+		return o.max - o.min + 1
+	}
+	return len(o.lista)
+}
+
+func (o *opcoesValidas) iniciais() map[string]int {
+	contagem := map[string]int{}
+	for _, op := range o.lista {
+		contagem[op] = 0
+	}
+	return contagem
+}
+
+func main() {
+	specOpcoes := flag.String("opcoes", "A,B,C", "opções da votação, como no VOTING_OPTIONS do servidor")
+	maxSelecoes := flag.Int("max-selecoes", 1, "máximo de opções por voto (MAX_SELECTIONS); ignorado com -tally approval ou irv")
+	revoto := flag.Bool("revoto", false, "o último voto de cada usuário vale (ALLOW_REVOTE)")
+	tally := flag.String("tally", apuracaoMaioria, "modo de apuração: plurality, approval ou irv (TALLY)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: replay [opções] <arquivo VOTE_LOG>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opcoes, err := lerOpcoes(*specOpcoes)
+	if err != nil {
+		log.Fatalf("Opções inválidas: %v", err)
+	}
+	switch *tally {
+	case apuracaoMaioria:
+	case apuracaoAprovacao, apuracaoRanking:
+		// Como no servidor: cada voto pode levar todas as opções.
+		*maxSelecoes = opcoes.total()
+	default:
+		log.Fatalf("-tally inválido: %q (use plurality, approval ou irv)", *tally)
+	}
+
+	registros, err := lerLog(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Erro ao ler o log: %v", err)
+	}
+
+	out, _ := json.MarshalIndent(recontar(registros, opcoes, *maxSelecoes, *revoto, *tally), "", "  ")
+	fmt.Println(string(out))
+}
+
+// Lê o log e ordena as linhas pelo horário em que cada voto foi contado.
+func lerLog(caminho string) ([]RegistroVoto, error) {
+	f, err := os.Open(caminho)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var registros []RegistroVoto
+	quando := map[int]time.Time{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		linha := strings.TrimSpace(scanner.Text())
+		if linha == "" {
+			continue
+		}
+		var r RegistroVoto
+		if err := json.Unmarshal([]byte(linha), &r); err != nil {
+			return nil, fmt.Errorf("linha %d: %w", n, err)
+		}
+		t, err := time.Parse(time.RFC3339Nano, r.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("linha %d: timestamp: %w", n, err)
+		}
+		quando[len(registros)] = t
+		registros = append(registros, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	idx := make([]int, len(registros))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return quando[idx[a]].Before(quando[idx[b]]) })
+	ordenados := make([]RegistroVoto, len(registros))
+	for i, j := range idx {
+		ordenados[i] = registros[j]
+	}
+	return ordenados, nil
+}
+
+// Reaplica as regras do servidor: opções válidas, sem repetição, no
+// máximo maxSelecoes, e um voto por usuário (ou o último, com revoto).
+// Com tally irv, as opções de cada voto são o ranking: o resultado conta
+// as primeiras preferências e as rodadas decidem o vencedor.
+func recontar(registros []RegistroVoto, opcoes *opcoesValidas, maxSelecoes int, revoto bool, tally string) Recontagem {
+	votos := map[string][]string{}
+	pesos := map[string]int{}
+	rejeitados := map[string]int{}
+
+	for _, r := range registros {
+		if r.Reset {
+			votos = map[string][]string{}
+			pesos = map[string]int{}
+			continue
+		}
+
+		if len(r.Opcoes) > maxSelecoes {
+			rejeitados["TOO_MANY_OPTIONS"]++
+			continue
+		}
+		valido := len(r.Opcoes) > 0
+		vistas := map[string]bool{}
+		for _, op := range r.Opcoes {
+			if !opcoes.valida(op) || vistas[op] {
+				valido = false
+			}
+			vistas[op] = true
+		}
+		if !valido {
+			rejeitados["INVALID_OPTION"]++
+			continue
+		}
+
+		if _, existe := votos[r.UserID]; existe && !revoto {
+			rejeitados["ALREADY_VOTED"]++
+			continue
+		}
+		peso := r.Peso
+		if peso == 0 {
+			peso = 1
+		}
+		votos[r.UserID] = r.Opcoes
+		pesos[r.UserID] = peso
+	}
+
+	res := Recontagem{Resultado: opcoes.iniciais(), Votos: len(votos)}
+	var cedulas [][]string
+	for user, escolhas := range votos {
+		if tally == apuracaoRanking {
+			res.Resultado[escolhas[0]] += pesos[user]
+			for i := 0; i < pesos[user]; i++ {
+				cedulas = append(cedulas, escolhas)
+			}
+			continue
+		}
+		for _, op := range escolhas {
+			res.Resultado[op] += pesos[user]
+		}
+	}
+	if tally == apuracaoRanking {
+		disputa := make([]string, 0, len(res.Resultado))
+		for op := range res.Resultado {
+			disputa = append(disputa, op)
+		}
+		res.Rodadas = votacao.RodadasIRV(cedulas, disputa)
+		if len(res.Rodadas) > 0 {
+			res.Vencedor = vencedor(res.Rodadas[len(res.Rodadas)-1].Contagem)
+		}
+	}
+	if len(rejeitados) > 0 {
+		res.Rejeitados = rejeitados
+	}
+	return res
+}
+
+// Mais votada na rodada que decide; "empate" quando mais de uma divide o
+// maior número, pois o replay não aplica o TIE_BREAK do servidor.
+func vencedor(contagem map[string]int) string {
+	maior := 0
+	var empatadas []string
+	for op, n := range contagem {
+		switch {
+		case n > maior:
+			maior = n
+			empatadas = []string{op}
+		case n == maior && n > 0:
+			empatadas = append(empatadas, op)
+		}
+	}
+	switch len(empatadas) {
+	case 0:
+		return ""
+	case 1:
+		return empatadas[0]
+	}
+	return "empate"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//
+// Arquivo de resultados ao vivo (LIVE_RESULTS_FILE).
+//
+// Uma goroutine regrava o arquivo a cada intervalo com a contagem atual,
+// para ferramentas externas (ex.: `watch cat`). A escrita vai para um
+// arquivo temporário no mesmo diretório e é trocada com rename, então
+// quem lê nunca vê um JSON pela metade.
+//
+
+// Conteúdo do arquivo ao vivo.
+type ResultadoAoVivo struct {
+	Resultado  map[string]int `json:"resultado"`
+	Vencedor   string         `json:"vencedor,omitempty"`
+	Final      bool           `json:"final"`
+	Atualizado string         `json:"atualizado"`
+}
+
+// ArquivoAoVivo regrava o arquivo quando a contagem muda.
+type ArquivoAoVivo struct {
+	caminho  string
+	apuracao *Apuracao
+
+	// Serializa as escritas; depois do final, nada mais é gravado.
+	mu         sync.Mutex
+	finalizado bool
+	// Contagem da última escrita, para pular regravações sem mudança.
+	ultima []byte
+}
+
+// Regrava o arquivo a cada intervalo até o resultado final.
+func (av *ArquivoAoVivo) executar(intervalo time.Duration) {
+	t := time.NewTicker(intervalo)
+	defer t.Stop()
+	for range t.C {
+		if !av.atualizar() {
+			return
+		}
+	}
+}
+
+// Grava a contagem atual se mudou; retorna false depois do final.
+func (av *ArquivoAoVivo) atualizar() bool {
+	av.mu.Lock()
+	defer av.mu.Unlock()
+	if av.finalizado {
+		return false
+	}
+
+	// A cópia é feita sob o stateMu: o arquivo sempre reflete um estado
+	// que existiu de fato.
+	parcial := av.apuracao.parcialCompleta()
+	contagem, _ := json.Marshal(parcial.Result)
+	if bytes.Equal(contagem, av.ultima) {
+		return true
+	}
+	if err := av.gravar(ResultadoAoVivo{Resultado: parcial.Result}); err != nil {
+		log.Printf("Erro ao gravar resultados ao vivo: %v\n", err)
+		return true
+	}
+	av.ultima = contagem
+	return true
+}
+
+// Grava o resultado final e encerra as atualizações periódicas.
+func (av *ArquivoAoVivo) finalizar(final BroadcastMsg) error {
+	av.mu.Lock()
+	defer av.mu.Unlock()
+	av.finalizado = true
+	return av.gravar(ResultadoAoVivo{Resultado: final.Result, Vencedor: final.Vencedor, Final: true})
+}
+
+// Grava o conteúdo de forma atômica (temporário + rename).
+func (av *ArquivoAoVivo) gravar(r ResultadoAoVivo) error {
+	r.Atualizado = time.Now().Format(time.RFC3339)
+	body, _ := json.MarshalIndent(r, "", "  ")
+
+	tmp, err := os.CreateTemp(filepath.Dir(av.caminho), ".resultados-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(body, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), av.caminho)
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Apuracao guarda o estado da votação compartilhado pelos workers.
+//
+// Registrar um voto toca só o estado do próprio usuário e os contadores
+// das opções escolhidas, então os workers não disputam um Lock único:
+//   - o estado por usuário fica em fatias, cada uma com seu Mutex;
+//   - cada opção tem contadores atômicos;
+//   - o registro segura o stateMu em modo leitura (compartilhado).
+//
+// Quem precisa de um estado exato (final, export, auditoria, reset)
+// segura o stateMu em modo escrita, o que espera os registros em
+// andamento e impede novos. As parciais leem os contadores sem isso:
+// cada valor é exato, mas um revoto em andamento pode aparecer pela
+// metade (a opção nova já contada e a antiga ainda não descontada); a
+// parcial seguinte corrige.
+type Apuracao struct {
+	validador   Validador
+	maxSelecoes int
+	// Lista de eleitores (nil = votação aberta). Somente leitura.
+	elegiveis map[string]bool
+	prazo     *Prazo
+	// Política de desempate do vencedor (TIE_BREAK) e sua semente.
+	desempate        string
+	sementeDesempate int64
+	// Revoto com "último voto vale" (ALLOW_REVOTE) e intervalo mínimo
+	// entre dois votos do mesmo usuário (REVOTE_COOLDOWN).
+	revoto         bool
+	cooldownRevoto time.Duration
+	// Exige Voto.Seq crescente por usuário (REQUIRE_VOTE_SEQ).
+	exigirSeq bool
+	// Vagas por opção (CAPS); nil = sem limite. Somente leitura.
+	limites map[string]int
+	// Procurações (DELEGATIONS_FILE); nil = votos só diretos.
+	delegacoes Delegacoes
+	// Máximo de usuários distintos (MAX_VOTERS); 0 = sem limite.
+	maxVotantes int64
+	// Modo de apuração (TALLY); vazio vale como ApuracaoMaioria.
+	modo string
+	// Parciais em modo delta (PARTIAL_DELTAS), com uma parcial completa a
+	// cada completaACada envios (PARTIAL_FULL_EVERY; 0 = nunca).
+	deltas        bool
+	completaACada int
+
+	// Marcada antes do snapshot final; votos depois disso são recusados.
+	encerrada atomic.Bool
+
+	// Contadores por opção (string -> *estatOpcao). Em modos de
+	// intervalo as chaves são criadas no primeiro voto.
+	opcoes *sync.Map
+	// Estado por usuário, dividido pelo hash do UserID.
+	fatias [numFatias]fatiaUsuarios
+	// Usuários com voto registrado, para a participação.
+	votantes atomic.Int64
+	// Votos recusados por motivo, relatados no "final".
+	rejeicoes Rejeicoes
+
+	// Sequência da última parcial e a contagem que ela refletia, base do
+	// próximo delta. Protegidas pelo parcialMu.
+	parcialMu     sync.Mutex
+	seqParcial    int64
+	ultimaParcial map[string]int
+}
+
+// Quantidade de fatias do estado por usuário.
+const numFatias = 64
+
+// Contadores de uma opção.
+type estatOpcao struct {
+	votos atomic.Int64
+	// Horário (UnixNano) do primeiro e do último voto; 0 = sem votos.
+	primeiro atomic.Int64
+	ultimo   atomic.Int64
+}
+
+// Estado dos usuários de uma fatia, protegido pelo mu dela.
+type fatiaUsuarios struct {
+	mu sync.Mutex
+	// Voto atual de cada usuário (opções separadas por vírgula).
+	votos map[string]string
+	// Horário do último voto aceito de cada usuário.
+	votoEm map[string]time.Time
+	// Seq do último voto aceito de cada usuário (com exigirSeq).
+	seqs map[string]int64
+	// Nonces de votos já contados -> UserID, para que a retentativa de um
+	// voto aceito seja confirmada de novo sem contar duas vezes. O nonce
+	// fica na fatia do dono do voto.
+	nonces map[string]string
+	// Usuários que votaram por procuração -> procurador.
+	procuradores map[string]string
+}
+
+func (f *fatiaUsuarios) limpar() {
+	f.votos = map[string]string{}
+	f.votoEm = map[string]time.Time{}
+	f.seqs = map[string]int64{}
+	f.nonces = map[string]string{}
+	f.procuradores = map[string]string{}
+}
+
+// Decisao descreve o que aconteceu com um voto, para que o worker envie
+// as mensagens correspondentes fora do Lock.
+type Decisao struct {
+	// Código de erro; vazio quando o voto foi aceito.
+	Codigo string
+	// Voto já contado reenviado com o mesmo nonce, ou reentregue igual
+	// pelo broker.
+	Retentativa bool
+	Escolhas    []string
+	// Opções do voto substituído, quando for um revoto.
+	Anteriores []string
+	Quando     time.Time
+	// Snapshot da parcial logo após o voto aceito: contagem, opções
+	// lotadas e participação.
+	Parcial BroadcastMsg
+}
+
+// Informa se o voto foi contado agora.
+func (d Decisao) Aceito() bool {
+	return d.Codigo == "" && !d.Retentativa
+}
+
+func novaApuracao(validador Validador, maxSelecoes int, elegiveis map[string]bool, prazo *Prazo) *Apuracao {
+	a := &Apuracao{
+		validador:   validador,
+		maxSelecoes: maxSelecoes,
+		elegiveis:   elegiveis,
+		prazo:       prazo,
+		desempate:   DesempateNenhum,
+		rejeicoes:   novasRejeicoes(),
+	}
+	a.zerar()
+	return a
+}
+
+// Descarta votos e contadores. Chamado na criação ou com o stateMu
+// travado em modo escrita.
+func (a *Apuracao) zerar() {
+	a.opcoes = &sync.Map{}
+	for i := range a.fatias {
+		a.fatias[i].limpar()
+	}
+	a.votantes.Store(0)
+	a.rejeicoes.zerar()
+
+	// Em modos de intervalo as chaves são criadas no primeiro voto.
+	for _, op := range a.validador.Iniciais() {
+		a.opcoes.Store(op, &estatOpcao{})
+	}
+}
+
+// Contadores da opção, criados no primeiro uso.
+func (a *Apuracao) estat(op string) *estatOpcao {
+	if st, ok := a.opcoes.Load(op); ok {
+		return st.(*estatOpcao)
+	}
+	st, _ := a.opcoes.LoadOrStore(op, &estatOpcao{})
+	return st.(*estatOpcao)
+}
+
+// Fatia que guarda o estado do usuário.
+func (a *Apuracao) fatia(user string) *fatiaUsuarios {
+	h := fnv.New32a()
+	h.Write([]byte(user))
+	return &a.fatias[h.Sum32()%numFatias]
+}
+
+// Valida e registra um voto. Não faz nenhuma operação de rede.
+func (a *Apuracao) processarVoto(v Voto) Decisao {
+	return a.processarVotoCtx(context.Background(), v)
+}
+
+// Igual a processarVoto, registrando as etapas como spans filhos do
+// trace do voto.
+func (a *Apuracao) processarVotoCtx(ctx context.Context, v Voto) Decisao {
+	_, validar := tracer.Start(ctx, "voto.validar")
+	codigo := a.verificarAcesso(v)
+	validar.End()
+	if codigo != "" {
+		a.rejeicoes.contarCodigo(codigo)
+		return Decisao{Codigo: codigo}
+	}
+
+	_, atualizar := tracer.Start(ctx, "voto.atualizar_estado")
+	defer atualizar.End()
+	d := a.registrar(v)
+	if d.Codigo != "" {
+		a.rejeicoes.contarCodigo(d.Codigo)
+	}
+	return d
+}
+
+// Regras que não dependem do estado dos votos; retorna o código de erro
+// ou "" se o usuário pode votar agora.
+func (a *Apuracao) verificarAcesso(v Voto) string {
+	// Resultado final já apurado.
+	if a.encerrada.Load() {
+		return CodEncerrada
+	}
+
+	// Antes de VOTING_START.
+	if a.prazo != nil && !a.prazo.Aberta() {
+		return CodNaoIniciada
+	}
+
+	// Votação pausada: nada é contado até o "resume".
+	if a.prazo != nil && a.prazo.Pausado() {
+		return CodPausada
+	}
+
+	// Votação fechada: só IDs da lista podem votar.
+	if a.elegiveis != nil && !a.elegiveis[v.UserID] {
+		return CodNaoElegivel
+	}
+	return ""
+}
+
+// Registra o voto sob o stateMu compartilhado e tira a parcial logo
+// depois, ainda antes de um reset poder zerar a contagem.
+func (a *Apuracao) registrar(v Voto) Decisao {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+
+	d := a.registrarNaFatia(v)
+	if d.Aceito() {
+		d.Parcial = a.snapshotParcial()
+		if a.prazo != nil {
+			a.prazo.RegistrarVoto()
+		}
+	}
+	return d
+}
+
+// Confere duplicidade e opções e conta o voto sob o Lock da fatia do
+// usuário. Chamado com o stateMu em modo leitura.
+func (a *Apuracao) registrarNaFatia(v Voto) Decisao {
+	f := a.fatia(v.UserID)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Retentativa de um voto já contado: apenas confirma de novo, com
+	// o voto atual do usuário para refazer o recibo.
+	if v.Nonce != "" && f.nonces[v.Nonce] == v.UserID {
+		return Decisao{
+			Retentativa: true,
+			Escolhas:    strings.Split(f.votos[v.UserID], ","),
+			Quando:      f.votoEm[v.UserID],
+		}
+	}
+	// Reentrega de um voto sem nonce que já está contado do mesmo jeito
+	// (ex.: contado no servidor anterior a um "dump-state" com "stop",
+	// antes do ack): confirma de novo em vez de recusar como duplicado.
+	if atual, ok := f.votos[v.UserID]; ok && v.reentrega && atual == strings.Join(v.Escolhas(), ",") && f.procuradores[v.UserID] == v.procurador {
+		return Decisao{
+			Retentativa: true,
+			Escolhas:    strings.Split(atual, ","),
+			Quando:      f.votoEm[v.UserID],
+		}
+	}
+
+	// Voto mais antigo (ou repetido) do que o último aceito do usuário:
+	// uma retentativa que a rede atrasou não desfaz um revoto posterior.
+	if a.exigirSeq {
+		if v.Seq <= 0 {
+			return Decisao{Codigo: CodSemSeq}
+		}
+		if ultimo, ok := f.seqs[v.UserID]; ok && v.Seq <= ultimo {
+			return Decisao{Codigo: CodSeqAntiga}
+		}
+	}
+
+	// Impede voto duplicado, a menos que o revoto esteja habilitado.
+	agora := time.Now()
+	anterior, exists := f.votos[v.UserID]
+	// Procuração só para quem ainda não votou, e quem votou por ela não
+	// vota mais diretamente.
+	if v.procurador != "" && exists {
+		return Decisao{Codigo: CodJaVotou}
+	}
+	if v.procurador == "" && f.procuradores[v.UserID] != "" {
+		return Decisao{Codigo: CodDelegado}
+	}
+	if exists && !a.revoto {
+		return Decisao{Codigo: CodJaVotou}
+	}
+	if exists && agora.Sub(f.votoEm[v.UserID]) < a.cooldownRevoto {
+		return Decisao{Codigo: CodCedoDemais}
+	}
+
+	// Validação das opções.
+	escolhas := v.Escolhas()
+	if codigo := validarEscolhas(a.validador, escolhas, a.maxSelecoes); codigo != "" {
+		return Decisao{Codigo: codigo}
+	}
+
+	var anteriores []string
+	if exists {
+		anteriores = strings.Split(anterior, ",")
+	}
+	// No ranking, a contagem ao vivo é só a das primeiras preferências;
+	// o ranking inteiro fica em f.votos para a apuração final.
+	contadas, descontadas := escolhas, anteriores
+	if a.modo == ApuracaoRanking {
+		contadas = escolhas[:min(1, len(escolhas))]
+		descontadas = anteriores[:min(1, len(anteriores))]
+	}
+
+	// Revoto: só mudam as opções que entram e as que saem. As que entram
+	// reservam a vaga (CAPS) atomicamente junto com a contagem.
+	// Um usuário novo ocupa um lugar de votante antes das vagas das
+	// opções, e o devolve se a opção estiver lotada.
+	if !exists && !a.ocuparVotante() {
+		return Decisao{Codigo: CodCapacidade}
+	}
+	novas, removidas := diferencaOpcoes(descontadas, contadas)
+	if !a.reservar(novas) {
+		if !exists {
+			a.votantes.Add(-1)
+		}
+		return Decisao{Codigo: CodOpcaoLotada}
+	}
+	for _, op := range removidas {
+		a.estat(op).votos.Add(-1)
+	}
+
+	// Registrando voto: um usuário conta uma vez, mesmo
+	// escolhendo várias opções.
+	f.votos[v.UserID] = strings.Join(escolhas, ",")
+	f.votoEm[v.UserID] = agora
+	if a.exigirSeq {
+		f.seqs[v.UserID] = v.Seq
+	}
+	if v.Nonce != "" {
+		f.nonces[v.Nonce] = v.UserID
+	}
+	if v.procurador != "" {
+		f.procuradores[v.UserID] = v.procurador
+	}
+	for _, op := range contadas {
+		st := a.estat(op)
+		st.primeiro.CompareAndSwap(0, agora.UnixNano())
+		for {
+			ultimo := st.ultimo.Load()
+			if ultimo >= agora.UnixNano() || st.ultimo.CompareAndSwap(ultimo, agora.UnixNano()) {
+				break
+			}
+		}
+	}
+
+	return Decisao{
+		Escolhas:   escolhas,
+		Anteriores: anteriores,
+		Quando:     agora,
+	}
+}
+
+// Conta um votante novo, respeitando o MAX_VOTERS com compare-and-swap,
+// como as vagas das opções. Chamado com o stateMu em modo leitura, que o
+// reset (modo escrita) não atravessa.
+func (a *Apuracao) ocuparVotante() bool {
+	if a.maxVotantes == 0 {
+		a.votantes.Add(1)
+		return true
+	}
+	for {
+		n := a.votantes.Load()
+		if n >= a.maxVotantes {
+			return false
+		}
+		if a.votantes.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// Separa as opções que entram no voto e as que saem dele num revoto.
+func diferencaOpcoes(anteriores, escolhas []string) (novas, removidas []string) {
+	for _, op := range escolhas {
+		if !slices.Contains(anteriores, op) {
+			novas = append(novas, op)
+		}
+	}
+	for _, op := range anteriores {
+		if !slices.Contains(escolhas, op) {
+			removidas = append(removidas, op)
+		}
+	}
+	return novas, removidas
+}
+
+// Fecha a apuração: nenhum voto é contado depois desta chamada.
+func (a *Apuracao) encerrar() {
+	a.encerrada.Store(true)
+}
+
+// Monta o broadcast final a partir de um snapshot consistente do estado.
+func (a *Apuracao) resultadoFinal() BroadcastMsg {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	contagem := a.contagemAtual()
+	primeiro, ultimo := a.horariosVotos()
+	final := BroadcastMsg{
+		Result:       contagem,
+		PrimeiroVoto: formataTempos(primeiro),
+		UltimoVoto:   formataTempos(ultimo),
+		Vencedor:     definirVencedor(contagem, ultimo, a.desempate, a.sementeDesempate),
+		Rejeicoes:    a.rejeicoes.copia(),
+	}
+	if a.modo == ApuracaoRanking {
+		opcoes := make([]string, 0, len(contagem))
+		for op := range contagem {
+			opcoes = append(opcoes, op)
+		}
+		final.Vencedor, final.Rodadas = apurarIRV(a.rankings(), opcoes, ultimo, a.desempate, a.sementeDesempate)
+	}
+	a.preencherParticipacao(&final)
+	return final
+}
+
+// Cópia dos contadores das opções.
+func (a *Apuracao) contagemAtual() map[string]int {
+	contagem := map[string]int{}
+	a.opcoes.Range(func(k, v any) bool {
+		contagem[k.(string)] = int(v.(*estatOpcao).votos.Load())
+		return true
+	})
+	return contagem
+}
+
+// Horários do primeiro e do último voto de cada opção com votos.
+func (a *Apuracao) horariosVotos() (primeiro, ultimo map[string]time.Time) {
+	primeiro, ultimo = map[string]time.Time{}, map[string]time.Time{}
+	a.opcoes.Range(func(k, v any) bool {
+		st := v.(*estatOpcao)
+		if p := st.primeiro.Load(); p != 0 {
+			primeiro[k.(string)] = time.Unix(0, p)
+		}
+		if u := st.ultimo.Load(); u != 0 {
+			ultimo[k.(string)] = time.Unix(0, u)
+		}
+		return true
+	})
+	return primeiro, ultimo
+}
+
+// Cópia da contagem para a "parcial". No modo delta, leva só as opções
+// que mudaram desde a parcial anterior. Chamado com o stateMu travado
+// (em qualquer modo).
+func (a *Apuracao) snapshotParcial() BroadcastMsg {
+	if !a.deltas {
+		return a.parcialAtual(a.contagemAtual())
+	}
+
+	a.parcialMu.Lock()
+	defer a.parcialMu.Unlock()
+
+	// A contagem é lida sob o parcialMu para que a ordem das sequências
+	// siga a ordem das leituras e os deltas somem certo.
+	contagem := a.contagemAtual()
+	a.seqParcial++
+	var parcial BroadcastMsg
+	if a.completaACada > 0 && a.seqParcial%int64(a.completaACada) == 0 {
+		parcial = a.parcialAtual(contagem)
+	} else {
+		parcial = BroadcastMsg{
+			Seq:      a.seqParcial,
+			Delta:    diferencaContagem(a.ultimaParcial, contagem),
+			Fechadas: a.fechadas(),
+		}
+		a.preencherParticipacao(&parcial)
+	}
+	a.ultimaParcial = contagem
+	return parcial
+}
+
+// Parcial com a contagem inteira. No modo delta, é marcada como completa
+// e carrega a sequência da última parcial, para que o cliente descarte
+// os deltas que ela já inclui; nesse modo é chamada com o parcialMu
+// travado.
+func (a *Apuracao) parcialAtual(contagem map[string]int) BroadcastMsg {
+	parcial := BroadcastMsg{
+		Result:   contagem,
+		Fechadas: a.fechadas(),
+	}
+	if a.deltas {
+		parcial.Seq = a.seqParcial
+		parcial.Completa = true
+	}
+	a.preencherParticipacao(&parcial)
+	return parcial
+}
+
+// Parcial completa pedida por um cliente que perdeu deltas. Usa o
+// stateMu em modo escrita, então reflete um estado exato.
+func (a *Apuracao) parcialCompleta() BroadcastMsg {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	a.parcialMu.Lock()
+	defer a.parcialMu.Unlock()
+
+	// Sem voto novo não há seq nova: a completa repete a da última
+	// parcial, com a contagem que ela refletia mais o que já mudou.
+	return a.parcialAtual(a.contagemAtual())
+}
+
+// Com lista de eleitores, informa a fração que já votou e quantos
+// faltam.
+func (a *Apuracao) preencherParticipacao(msg *BroadcastMsg) {
+	preencherModo(msg, a.votantes.Load())
+	if len(a.elegiveis) == 0 {
+		return
+	}
+	msg.Eleitores = len(a.elegiveis)
+	msg.Votantes = int(a.votantes.Load())
+	msg.Pendentes = msg.Eleitores - msg.Votantes
+	msg.Participacao = float64(msg.Votantes) / float64(msg.Eleitores)
+}
+
+// Copia o mapa usuário -> opção com o stateMu em modo escrita.
+func (a *Apuracao) copiaVotos() map[string]string {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	snapshot := map[string]string{}
+	for i := range a.fatias {
+		for k, v := range a.fatias[i].votos {
+			snapshot[k] = v
+		}
+	}
+	return snapshot
+}
+
+// Descarta todos os votos e recomeça a contagem do zero. Retorna a
+// parcial zerada para ser anunciada aos clientes e o instante do reset,
+// tomado com o stateMu em modo escrita para ordenar corretamente com os
+// votos no log.
+func (a *Apuracao) reiniciar() (BroadcastMsg, time.Time) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	agora := time.Now()
+	a.zerar()
+
+	// O delta não expressa o zeramento: o reset sempre vai completo.
+	a.parcialMu.Lock()
+	defer a.parcialMu.Unlock()
+	contagem := a.contagemAtual()
+	if a.deltas {
+		a.seqParcial++
+		a.ultimaParcial = contagem
+	}
+	return a.parcialAtual(copiaMapa(contagem)), agora
+}
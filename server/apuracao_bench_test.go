@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Referência com o desenho anterior: um único Mutex guardando todos os
+// mapas, com o mesmo trabalho por voto (duplicidade, contagem, horários
+// e cópia da parcial).
+type apuracaoMutexUnico struct {
+	mu           sync.Mutex
+	votos        map[string]string
+	votoEm       map[string]time.Time
+	contagem     map[string]int
+	primeiroVoto map[string]time.Time
+	ultimoVoto   map[string]time.Time
+}
+
+func novaApuracaoMutexUnico() *apuracaoMutexUnico {
+	return &apuracaoMutexUnico{
+		votos:        map[string]string{},
+		votoEm:       map[string]time.Time{},
+		contagem:     map[string]int{},
+		primeiroVoto: map[string]time.Time{},
+		ultimoVoto:   map[string]time.Time{},
+	}
+}
+
+func (a *apuracaoMutexUnico) processarVoto(v Voto) map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.votos[v.UserID]; ok {
+		return nil
+	}
+	agora := time.Now()
+	escolhas := v.Escolhas()
+	a.votos[v.UserID] = strings.Join(escolhas, ",")
+	a.votoEm[v.UserID] = agora
+	for _, op := range escolhas {
+		a.contagem[op]++
+		if _, ok := a.primeiroVoto[op]; !ok {
+			a.primeiroVoto[op] = agora
+		}
+		a.ultimoVoto[op] = agora
+	}
+	return copiaMapa(a.contagem)
+}
+
+var opcoesBench = []string{"A", "B", "C", "D"}
+
+// Votos de usuários distintos registrados em paralelo, com a parcial de
+// cada um. O ganho do estado fatiado aparece com vários núcleos; compare
+// com: go test -run '^$' -bench Apuracao -cpu 1,4,8
+func BenchmarkApuracaoMutexUnico(b *testing.B) {
+	a := novaApuracaoMutexUnico()
+	var proximo atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := proximo.Add(1)
+			a.processarVoto(Voto{UserID: "u" + strconv.FormatInt(n, 10), Option: opcoesBench[n%4]})
+		}
+	})
+}
+
+func BenchmarkApuracaoFatiada(b *testing.B) {
+	validador, err := novoValidador("A,B,C,D")
+	if err != nil {
+		b.Fatal(err)
+	}
+	a := novaApuracao(validador, 1, nil, nil)
+	var proximo atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := proximo.Add(1)
+			a.registrar(Voto{UserID: "u" + strconv.FormatInt(n, 10), Option: opcoesBench[n%4]})
+		}
+	})
+}
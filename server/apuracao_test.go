@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Votos simultâneos do mesmo UserID devem contar exatamente uma vez.
+// Rode com -race para que uma regressão no Lock apareça como data race.
+func TestVotoDuplicadoConcorrenteContaUmaVez(t *testing.T) {
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := novaApuracao(validador, 1, nil, nil)
+
+	const tentativas = 200
+	decisoes := make([]Decisao, tentativas)
+
+	var wg sync.WaitGroup
+	inicio := make(chan struct{})
+	for i := 0; i < tentativas; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-inicio // Solta todas as goroutines ao mesmo tempo.
+			decisoes[i] = a.processarVoto(Voto{UserID: "mesmo_usuario", Option: "A"})
+		}(i)
+	}
+	close(inicio)
+	wg.Wait()
+
+	confirmacoes := 0
+	for _, d := range decisoes {
+		switch {
+		case d.Aceito():
+			confirmacoes++
+		case d.Codigo != CodJaVotou:
+			t.Errorf("decisão inesperada para voto duplicado: %+v", d)
+		}
+	}
+
+	if confirmacoes != 1 {
+		t.Errorf("confirmações = %d, esperado 1", confirmacoes)
+	}
+
+	final := a.resultadoFinal()
+	if final.Result["A"] != 1 {
+		t.Errorf("contagem de A = %d, esperado 1", final.Result["A"])
+	}
+	if n := len(a.copiaVotos()); n != 1 {
+		t.Errorf("votos registrados = %d, esperado 1", n)
+	}
+}
+
+// Opções fora do conjunto configurado são recusadas com o código certo e
+// não mexem na contagem; as válidas somam um voto cada.
+func TestProcessarVotoValidaOpcoes(t *testing.T) {
+	casos := []struct {
+		nome   string
+		voto   Voto
+		codigo string
+	}{
+		{"opção válida", Voto{UserID: "u1", Option: "A"}, ""},
+		{"outra opção válida", Voto{UserID: "u2", Option: "C"}, ""},
+		{"quarta opção", Voto{UserID: "u3", Option: "D"}, CodOpcaoInvalida},
+		{"minúscula", Voto{UserID: "u4", Option: "a"}, CodOpcaoInvalida},
+		{"vazia", Voto{UserID: "u5", Option: ""}, CodOpcaoInvalida},
+		{"com espaço", Voto{UserID: "u6", Option: "A "}, CodOpcaoInvalida},
+		{"várias com limite 1", Voto{UserID: "u7", Options: []string{"A", "B"}}, CodSelecoesDemais},
+		{"válida depois das recusas", Voto{UserID: "u8", Option: "B"}, ""},
+	}
+
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := novaApuracao(validador, 1, nil, nil)
+
+	esperado := map[string]int{"A": 0, "B": 0, "C": 0}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			d := a.processarVoto(c.voto)
+			if d.Codigo != c.codigo {
+				t.Fatalf("código = %q, esperado %q", d.Codigo, c.codigo)
+			}
+			if c.codigo == "" {
+				esperado[c.voto.Option]++
+			}
+			if got := a.resultadoFinal().Result; !mapasIguais(got, esperado) {
+				t.Errorf("contagem = %v, esperado %v", got, esperado)
+			}
+		})
+	}
+}
+
+// RANGE só aceita limites não negativos, com mínimo até o máximo; um
+// negativo dá erro claro em vez de uma falha do Atoi.
+func TestIntervaloDeOpcoes(t *testing.T) {
+	casos := []struct {
+		spec string
+		ok   bool
+	}{
+		{"RANGE:0-10", true},
+		{"RANGE: 1 - 5 ", true},
+		{"RANGE:3-3", true},
+		{"RANGE:-5-5", false},
+		{"RANGE:1--5", false},
+		{"RANGE:5-1", false},
+		{"RANGE:1-", false},
+		{"RANGE:10", false},
+	}
+	for _, c := range casos {
+		_, err := novoValidador(c.spec)
+		if (err == nil) != c.ok {
+			t.Errorf("%q: erro = %v, esperado ok=%v", c.spec, err, c.ok)
+		}
+		if strings.Contains(c.spec, "-5") && err != nil && !strings.Contains(err.Error(), "não negativos") {
+			t.Errorf("%q: erro %q não diz que só valores não negativos são aceitos", c.spec, err)
+		}
+	}
+
+	validador, err := novoValidador("RANGE:0-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validador.Valid("0") || !validador.Valid("10") || validador.Valid("-1") || validador.Valid("11") {
+		t.Error("RANGE:0-10 deveria aceitar de 0 a 10, inclusive, e só isso")
+	}
+}
+
+func mapasIguais(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Um voto por procuração conta uma cédula por representado; quem já
+// votou não pode ser representado e quem foi representado não vota mais.
+func TestVotoPorProcuracao(t *testing.T) {
+	validador, err := novoValidador("A,B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := novaApuracao(validador, 1, nil, nil)
+	a.revoto = true
+	delegacoes := Delegacoes{"proc": {"ana": true, "bruno": true}}
+
+	if _, codigo := delegacoes.cedulas(Voto{UserID: "proc", Option: "A", DelegateFor: []string{"carla"}}); codigo != CodDelegacaoNegada {
+		t.Fatalf("procuração inexistente: código %q, esperado %q", codigo, CodDelegacaoNegada)
+	}
+
+	if d := a.processarVoto(Voto{UserID: "bruno", Option: "B"}); !d.Aceito() {
+		t.Fatalf("voto direto de bruno recusado: %+v", d)
+	}
+
+	cedulas, codigo := delegacoes.cedulas(Voto{UserID: "proc", Option: "A", DelegateFor: []string{"ana", "bruno", "ana"}})
+	if codigo != "" || len(cedulas) != 3 {
+		t.Fatalf("cédulas = %d (código %q), esperado 3", len(cedulas), codigo)
+	}
+	esperado := map[string]string{"proc": "", "ana": "", "bruno": CodJaVotou}
+	for _, c := range cedulas {
+		if d := a.processarVoto(c); d.Codigo != esperado[c.UserID] {
+			t.Errorf("cédula de %s: código %q, esperado %q", c.UserID, d.Codigo, esperado[c.UserID])
+		}
+	}
+
+	if d := a.processarVoto(Voto{UserID: "ana", Option: "B"}); d.Codigo != CodDelegado {
+		t.Errorf("voto direto de ana após a procuração: código %q, esperado %q", d.Codigo, CodDelegado)
+	}
+
+	final := a.resultadoFinal()
+	if final.Result["A"] != 2 || final.Result["B"] != 1 {
+		t.Errorf("contagem = %v, esperado A:2 B:1", final.Result)
+	}
+}
+
+// Com MAX_VOTERS, usuários novos além do limite são recusados com
+// CAPACITY, mas quem já votou ainda pode revotar.
+func TestLimiteDeVotantes(t *testing.T) {
+	validador, err := novoValidador("A,B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := novaApuracao(validador, 1, nil, nil)
+	a.revoto = true
+	a.maxVotantes = 2
+
+	for _, user := range []string{"ana", "bruno"} {
+		if d := a.processarVoto(Voto{UserID: user, Option: "A"}); !d.Aceito() {
+			t.Fatalf("voto de %s recusado: %+v", user, d)
+		}
+	}
+	if d := a.processarVoto(Voto{UserID: "carla", Option: "A"}); d.Codigo != CodCapacidade {
+		t.Errorf("terceiro votante: código %q, esperado %q", d.Codigo, CodCapacidade)
+	}
+	if d := a.processarVoto(Voto{UserID: "ana", Option: "B"}); !d.Aceito() {
+		t.Errorf("revoto de ana recusado: %+v", d)
+	}
+
+	final := a.resultadoFinal()
+	if final.Result["A"] != 1 || final.Result["B"] != 1 {
+		t.Errorf("contagem = %v, esperado A:1 B:1", final.Result)
+	}
+}
+
+func TestApuracaoPorRanking(t *testing.T) {
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := novaApuracao(validador, 3, nil, nil)
+	a.modo = ApuracaoRanking
+
+	// A e B empatam nas primeiras preferências, sem maioria; C sai e a
+	// cédula dele passa para B, que chega à maioria na segunda rodada.
+	rankings := map[string][]string{
+		"ana":   {"A", "B"},
+		"bruno": {"A"},
+		"carla": {"B", "A"},
+		"davi":  {"B", "C"},
+		"elisa": {"C", "B", "A"},
+		"fabio": {"B"},
+		"gil":   {"A", "C"},
+	}
+	for user, r := range rankings {
+		if d := a.processarVoto(Voto{UserID: user, Ranking: r}); !d.Aceito() {
+			t.Fatalf("voto de %s recusado: %+v", user, d)
+		}
+	}
+
+	final := a.resultadoFinal()
+	if final.Result["A"] != 3 || final.Result["B"] != 3 || final.Result["C"] != 1 {
+		t.Errorf("primeiras preferências = %v, esperado A:3 B:3 C:1", final.Result)
+	}
+	if final.Vencedor != "B" {
+		t.Errorf("vencedor = %q, esperado B", final.Vencedor)
+	}
+	if len(final.Rodadas) != 2 {
+		t.Fatalf("rodadas = %+v, esperadas 2", final.Rodadas)
+	}
+	if r := final.Rodadas[0]; len(r.Eliminadas) != 1 || r.Eliminadas[0] != "C" {
+		t.Errorf("eliminadas na rodada 1 = %v, esperado [C]", r.Eliminadas)
+	}
+	if r := final.Rodadas[1]; r.Contagem["B"] != 4 || r.Contagem["A"] != 3 {
+		t.Errorf("rodada 2 = %v, esperado A:3 B:4", r.Contagem)
+	}
+}
+
+// O estado gravado pelo dump-state, passado por JSON, recria a contagem
+// e a deduplicação num servidor novo.
+func TestEstadoTransferidoEntreServidores(t *testing.T) {
+	validador, err := novoValidador("A,B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	antigo := novaApuracao(validador, 1, nil, novoPrazo(time.Minute))
+	for user, op := range map[string]string{"ana": "A", "bruno": "B", "carla": "A"} {
+		if d := antigo.processarVoto(Voto{UserID: user, Option: op, Nonce: "n-" + user}); !d.Aceito() {
+			t.Fatalf("voto de %s recusado: %+v", user, d)
+		}
+	}
+	stateMu.Lock()
+	estado := antigo.estadoTravado()
+	stateMu.Unlock()
+
+	body, _ := json.Marshal(estado)
+	var lido EstadoServidor
+	if err := json.Unmarshal(body, &lido); err != nil {
+		t.Fatal(err)
+	}
+	prazo, err := lido.prazo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	novo := novaApuracao(validador, 1, nil, prazo)
+	if err := novo.restaurar(lido); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := novo.processarVoto(Voto{UserID: "ana", Option: "A", Nonce: "n-ana"}); !d.Retentativa {
+		t.Errorf("reentrega do voto de ana: %+v, esperada retentativa", d)
+	}
+	if d := novo.processarVoto(Voto{UserID: "bruno", Option: "A"}); d.Codigo != CodJaVotou {
+		t.Errorf("segundo voto de bruno: código %q, esperado %q", d.Codigo, CodJaVotou)
+	}
+	// Sem nonce, a reentrega do broker igual ao voto gravado só confirma;
+	// uma reentrega com outra opção continua duplicada.
+	if d := novo.processarVoto(Voto{UserID: "carla", Option: "A", reentrega: true}); !d.Retentativa {
+		t.Errorf("reentrega sem nonce do voto de carla: %+v, esperada retentativa", d)
+	}
+	if d := novo.processarVoto(Voto{UserID: "carla", Option: "B", reentrega: true}); d.Codigo != CodJaVotou {
+		t.Errorf("reentrega de carla em outra opção: código %q, esperado %q", d.Codigo, CodJaVotou)
+	}
+	if d := novo.processarVoto(Voto{UserID: "davi", Option: "B"}); !d.Aceito() {
+		t.Errorf("voto novo recusado: %+v", d)
+	}
+
+	final := novo.resultadoFinal()
+	if final.Result["A"] != 2 || final.Result["B"] != 2 {
+		t.Errorf("contagem = %v, esperado A:2 B:2", final.Result)
+	}
+	if n := novo.votantes.Load(); n != 4 {
+		t.Errorf("votantes = %d, esperado 4", n)
+	}
+	fim, _ := prazo.Fim()
+	if time.Until(fim) < 50*time.Second {
+		t.Errorf("prazo restaurado termina às %v, esperado ~1min", fim)
+	}
+
+	// O "online" do servidor novo anuncia o prazo restaurado.
+	tr := novoTransporteMemoria()
+	tr.Vincular("cliente", exchangeBroadcast)
+	broadcast, _ := tr.Consume("cliente", "", true, false, false, false, nil)
+	if err := enviarOnline(tr, validador, prazo); err != nil {
+		t.Fatal(err)
+	}
+	var online BroadcastMsg
+	json.Unmarshal((<-broadcast).Body, &online)
+	if online.Prazo != fim.Format(time.RFC3339) {
+		t.Errorf("online anuncia o prazo %q, esperado %q", online.Prazo, fim.Format(time.RFC3339))
+	}
+}
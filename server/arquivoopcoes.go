@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Opção descrita no arquivo de opções (-options-file).
+type OpcaoArquivo struct {
+	Chave string `json:"chave"`
+	// Texto exibido pelos clientes no lugar da chave (ex.: "Rust").
+	Rotulo string `json:"rotulo,omitempty"`
+	// Vagas da opção, como no CAPS; 0 = sem limite.
+	Vagas int `json:"vagas,omitempty"`
+	// Cor de exibição (ex.: "#dea584"), repassada aos clientes.
+	Cor string `json:"cor,omitempty"`
+}
+
+// Formato do arquivo: {"opcoes": [{"chave": "A", "rotulo": "Go"}, ...]}.
+type ArquivoOpcoes struct {
+	Opcoes []OpcaoArquivo `json:"opcoes"`
+}
+
+// Lê o arquivo de opções e monta o validador com rótulos e cores, mais
+// os limites de vagas declarados nele (nil se nenhum).
+func carregarArquivoOpcoes(caminho string) (*conjuntoOpcoes, map[string]int, error) {
+	f, err := os.Open(caminho)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var arq ArquivoOpcoes
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&arq); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", caminho, err)
+	}
+
+	c := &conjuntoOpcoes{
+		permitidas: map[string]bool{},
+		rotulos:    map[string]string{},
+		cores:      map[string]string{},
+	}
+	var limites map[string]int
+	for i, op := range arq.Opcoes {
+		chave := strings.TrimSpace(op.Chave)
+		if chave == "" {
+			return nil, nil, fmt.Errorf("%s: opção %d sem chave", caminho, i+1)
+		}
+		if c.permitidas[chave] {
+			return nil, nil, fmt.Errorf("%s: opção %q repetida", caminho, chave)
+		}
+		if op.Vagas < 0 {
+			return nil, nil, fmt.Errorf("%s: vagas inválidas para %q: %d", caminho, chave, op.Vagas)
+		}
+
+		c.permitidas[chave] = true
+		c.ordem = append(c.ordem, chave)
+		if op.Rotulo != "" {
+			c.rotulos[chave] = op.Rotulo
+		}
+		if op.Cor != "" {
+			c.cores[chave] = op.Cor
+		}
+		if op.Vagas > 0 {
+			if limites == nil {
+				limites = map[string]int{}
+			}
+			limites[chave] = op.Vagas
+		}
+	}
+	if len(c.ordem) == 0 {
+		return nil, nil, fmt.Errorf("%s: nenhuma opção configurada", caminho)
+	}
+	return c, limites, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Modo de medição (BENCH_MODE): os workers só contam. Confirmações,
+// erros, parciais e auditoria não são publicados, para medir o caminho
+// de contagem (stateMu e fatias) sem o de publicação (amqpMu). O final
+// traz quantas cédulas foram processadas e em quanto tempo.
+type Bench struct {
+	processados atomic.Int64
+	// Horário (UnixNano) da primeira e da última cédula processada.
+	primeiro atomic.Int64
+	ultimo   atomic.Int64
+}
+
+// Resumo do modo de medição, no campo "bench" do final.
+type ResumoBench struct {
+	Processados     int64   `json:"processados"`
+	Segundos        float64 `json:"segundos"`
+	VotosPorSegundo float64 `json:"votosPorSegundo"`
+}
+
+// Conta uma cédula processada, aceita ou recusada.
+func (b *Bench) registrar() {
+	agora := time.Now().UnixNano()
+	b.processados.Add(1)
+	b.primeiro.CompareAndSwap(0, agora)
+	for {
+		ultimo := b.ultimo.Load()
+		if agora <= ultimo || b.ultimo.CompareAndSwap(ultimo, agora) {
+			return
+		}
+	}
+}
+
+// Total processado e o tempo entre a primeira e a última cédula.
+func (b *Bench) resumo() *ResumoBench {
+	r := &ResumoBench{Processados: b.processados.Load()}
+	if primeiro := b.primeiro.Load(); primeiro != 0 {
+		r.Segundos = time.Duration(b.ultimo.Load() - primeiro).Seconds()
+	}
+	if r.Segundos > 0 {
+		r.VotosPorSegundo = float64(r.Processados) / r.Segundos
+	}
+	return r
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Prazos de publicação. Mensagens por usuário falham rápido; o resultado
+// final tem mais folga por ser a mensagem mais importante da votação.
+var (
+	publishTimeout      = 2 * time.Second
+	publishTimeoutFinal = 10 * time.Second
+)
+
+// Tamanho a partir do qual o corpo dos broadcasts é comprimido com gzip.
+// Zero desliga a compressão.
+var compressThreshold int
+
+// Publica os broadcasts com mandatory=true, fazendo o broker devolver
+// (NotifyReturn) mensagens sem nenhuma fila ligada. Útil para achar
+// bindings errados, mas sem clientes conectados todo broadcast volta.
+var broadcastMandatory bool
+
+// Votação com resultados ocultos (HIDE_PARTIALS): enviarParcial não
+// publica nada, nem no broadcast nem no stream.
+var ocultarParciais bool
+
+// Função geral de envio de mensagens para a exchange de broadcast, no
+// codec configurado (JSON por padrão), na rota das mensagens sem idioma.
+func publishJSON(ch Transport, msg BroadcastMsg, timeout time.Duration) error {
+	return publicarBroadcast(ch, rotaNeutra(), msg, timeout)
+}
+
+// Publica na exchange de broadcast com a routing key dada (idiomas.go).
+func publicarBroadcast(ch Transport, rota string, msg BroadcastMsg, timeout time.Duration) error {
+	// Proteção: O canal AMQP não é thread-safe para publish concorrente
+	esperaInicio := time.Now()
+	amqpMu.Lock()
+	defer amqpMu.Unlock()
+	esperaAmqpMu.observar(time.Since(esperaInicio))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body, _ := codecEnvio.Marshal(msg)
+
+	pub := amqp.Publishing{
+		ContentType: codecEnvio.ContentType(),
+		Body:        body,
+	}
+
+	// Payloads grandes (finais com muitos campos) vão comprimidos; o
+	// cliente descomprime de acordo com o ContentEncoding.
+	if compressThreshold > 0 && len(body) > compressThreshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		if err := zw.Close(); err == nil {
+			pub.Body = buf.Bytes()
+			pub.ContentEncoding = "gzip"
+		}
+	}
+
+	publishInicio := time.Now()
+	err := ch.PublishWithContext(
+		ctx,
+		exchangeBroadcast, // Fanout, ou topic com BROADCAST_LANGS.
+		rota,
+		broadcastMandatory,
+		false,
+		pub,
+	)
+	duracaoPublish.observar(time.Since(publishInicio))
+	return err
+}
+
+// Registra quando o broker bloqueia as publicações por alarme de memória
+// ou disco. Sem isso, o bloqueio aparece só como timeout nos publishes.
+func logarBloqueio(conn *amqp.Connection) {
+	bloqueios := conn.NotifyBlocked(make(chan amqp.Blocking, 4))
+	go func() {
+		for b := range bloqueios {
+			if b.Active {
+				log.Printf("Broker aplicando controle de fluxo (blocked): %s\n", b.Reason)
+			} else {
+				log.Println("Broker liberou as publicações (unblocked).")
+			}
+		}
+	}()
+}
+
+// Registra as mensagens devolvidas pelo broker por não terem rota
+// (publicadas com mandatory=true), que de outra forma sumiriam sem erro.
+func logarDevolvidas(ch *amqp.Channel) {
+	devolvidas := ch.NotifyReturn(make(chan amqp.Return, 64))
+	go func() {
+		for r := range devolvidas {
+			body := r.Body
+			if r.ContentEncoding == "gzip" {
+				if zr, err := gzip.NewReader(bytes.NewReader(r.Body)); err == nil {
+					body, _ = io.ReadAll(zr)
+				}
+			}
+			var msg struct {
+				Tipo string `json:"tipo"`
+				Cmd  string `json:"cmd"`
+			}
+			codecDoTipo(r.ContentType).Unmarshal(body, &msg)
+			tipo := msg.Tipo
+			if tipo == "" {
+				tipo = msg.Cmd
+			}
+
+			log.Printf("Mensagem devolvida pelo broker: exchange=%q routing_key=%q tipo=%q motivo=%d %s\n",
+				r.Exchange, r.RoutingKey, tipo, r.ReplyCode, r.ReplyText)
+		}
+	}()
+}
+
+// Confirma o voto repetindo as opções registradas (e as anteriores, no
+// revoto) para que o eleitor confira o que foi contado.
+func enviarConfirmacao(ch Transport, user, lang string, escolhas, anteriores []string, recibo *Recibo) error {
+	return publicarPorIdioma(ch, lang, func(idioma string) BroadcastMsg {
+		return BroadcastMsg{
+			Tipo:     "confirmacao",
+			UserID:   user,
+			Codigo:   CodVotoRegistrado,
+			Mensagem: mensagem(idioma, CodVotoRegistrado),
+			Opcao:    strings.Join(escolhas, ","),
+			Anterior: strings.Join(anteriores, ","),
+			Recibo:   recibo,
+		}
+	})
+}
+
+func enviarErro(ch Transport, user, lang, codigo string) error {
+	return publicarPorIdioma(ch, lang, func(idioma string) BroadcastMsg {
+		return BroadcastMsg{
+			Tipo:     "erro",
+			UserID:   user,
+			Codigo:   codigo,
+			Mensagem: mensagem(idioma, codigo),
+		}
+	})
+}
+
+// Publica a parcial montada pela Apuracao (contagem, lotadas e participação).
+func enviarParcial(ch Transport, parcial BroadcastMsg) error {
+	if ocultarParciais {
+		return nil
+	}
+	parcial.Tipo = "parcial"
+	difusao.publicar(parcial)
+	return publishJSON(ch, parcial, publishTimeout)
+}
+
+// Erro do snapshot manual com HIDE_PARTIALS.
+var errParciaisOcultas = errors.New("parciais ocultas (HIDE_PARTIALS)")
+
+// Publica a contagem atual como parcial completa, fora do fluxo dos
+// votos (comando "snapshot" e POST /snapshot).
+func enviarInstantaneo(ch Transport, apuracao *Apuracao) error {
+	if ocultarParciais {
+		return errParciaisOcultas
+	}
+	return enviarParcial(ch, apuracao.parcialCompleta())
+}
+
+func enviarShutdown(ch Transport, motivo string) error {
+	return publishJSON(ch, BroadcastMsg{
+		Tipo:     "shutdown",
+		Mensagem: "O servidor foi desligado. Cliente encerrando...",
+		Motivo:   motivo,
+	}, publishTimeout)
+}
+
+// Publica o resultado final; o chamador preenche a contagem e os metadados.
+func enviarFinal(ch Transport, final BroadcastMsg, politica PoliticaEntrega) error {
+	final.Tipo = "final"
+	difusao.publicar(final)
+	err := politica.entregar("broadcast", func() error {
+		return publishJSON(ch, final, publishTimeoutFinal)
+	})
+	if err != nil {
+		return err
+	}
+	log.Println("Resultado final enviado a todos os clientes.")
+	return nil
+}
+
+// Anuncia o servidor no ar com o início e o fim do prazo (o restaurado,
+// com -restore-state).
+func enviarOnline(ch Transport, validador Validador, prazo *Prazo) error {
+	inicio, agendada := prazo.Abertura()
+	fim, _ := prazo.Fim()
+	msg := BroadcastMsg{
+		Tipo:            "server",
+		Status:          "online",
+		TimeoutSegundos: int(fim.Sub(inicio).Seconds()),
+		Inicio:          inicio.Format(time.RFC3339),
+		Prazo:           fim.Format(time.RFC3339),
+		ParciaisOcultas: ocultarParciais,
+	}
+	if agendada {
+		msg.Abertura = inicio.Format(time.RFC3339)
+	}
+	anunciarOpcoes(&msg, validador)
+	return publishJSON(ch, msg, publishTimeout)
+}
+
+// Avisa que a votação agendada abriu e até quando vai.
+func enviarAbertura(ch Transport, fim time.Time) error {
+	return publishJSON(ch, BroadcastMsg{
+		Tipo:   "server",
+		Status: "open",
+		Prazo:  fim.Format(time.RFC3339),
+	}, publishTimeout)
+}
+
+// Anuncia a lista de opções válidas para que os clientes montem o
+// prompt e validem a entrada antes de publicar.
+func enviarOpcoes(ch Transport, validador Validador) error {
+	msg := BroadcastMsg{
+		Tipo:            "opcoes",
+		ParciaisOcultas: ocultarParciais,
+	}
+	anunciarOpcoes(&msg, validador)
+	return publishJSON(ch, msg, publishTimeout)
+}
+
+// Preenche o conjunto de opções (lista ou intervalo, rótulos, cores, modo
+// de apuração e prova de trabalho). Vai também no "online" e em cada
+// heartbeat, porque o "opcoes" só sai na partida e no reset e um cliente
+// que conecta depois não o recebe.
+func anunciarOpcoes(msg *BroadcastMsg, validador Validador) {
+	msg.Opcoes = validador.Iniciais()
+	msg.DificuldadePoW = dificuldadePoW
+	if modoApuracao != ApuracaoMaioria {
+		msg.Apuracao = modoApuracao
+	}
+	if r, ok := validador.(*intervaloOpcoes); ok {
+		msg.Intervalo = fmt.Sprintf("%d-%d", r.min, r.max)
+	}
+	if c, ok := validador.(*conjuntoOpcoes); ok && len(c.rotulos)+len(c.cores) > 0 {
+		msg.Rotulos = c.rotulos
+		msg.Cores = c.cores
+	}
+}
+
+func enviarOffline(ch Transport) error {
+	return publishJSON(ch, BroadcastMsg{
+		Tipo:   "server",
+		Status: "offline",
+	}, publishTimeout)
+}
+
+// Registro de um voto aceito, republicado na exchange de auditoria.
+type RegistroAudit struct {
+	UserID    string   `json:"userId"`
+	Opcoes    []string `json:"opcoes"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// Republica o voto aceito na exchange topic de auditoria, com routing
+// key "audit.<opção>" (votos de múltipla escolha usam "audit.A.C").
+// As mensagens são persistentes para sobreviver a reinícios do broker.
+func publicarAuditoria(ch Transport, user string, escolhas []string, quando time.Time) error {
+	amqpMu.Lock()
+	defer amqpMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	body, _ := json.Marshal(RegistroAudit{
+		UserID:    user,
+		Opcoes:    escolhas,
+		Timestamp: quando.Format(time.RFC3339Nano),
+	})
+
+	return ch.PublishWithContext(
+		ctx,
+		exchangeAudit,
+		"audit."+strings.Join(escolhas, "."),
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	)
+}
+
+func enviarPausa(ch Transport) error {
+	return publishJSON(ch, BroadcastMsg{
+		Tipo:   "server",
+		Status: "paused",
+	}, publishTimeout)
+}
+
+func enviarRetomada(ch Transport, fim time.Time) error {
+	return publishJSON(ch, BroadcastMsg{
+		Tipo:   "server",
+		Status: "resumed",
+		Prazo:  fim.Format(time.RFC3339),
+	}, publishTimeout)
+}
+
+// Novo prazo depois de um "extend" ou "shorten". Com a votação pausada,
+// é o fim estimado se ela fosse retomada agora.
+func enviarNovoPrazo(ch Transport, fim time.Time) error {
+	return publishJSON(ch, BroadcastMsg{
+		Tipo:   "server",
+		Status: "deadline",
+		Prazo:  fim.Format(time.RFC3339),
+	}, publishTimeout)
+}
+
+// Heartbeat periódico: mostra aos clientes que o servidor segue vivo e
+// repete o prazo atual (status "paused" durante a pausa) e as opções.
+func enviarTempo(ch Transport, prazo *Prazo, validador Validador) error {
+	fim, pausado := prazo.Fim()
+	msg := BroadcastMsg{
+		Tipo:            "tempo",
+		Prazo:           fim.Format(time.RFC3339),
+		ParciaisOcultas: ocultarParciais,
+	}
+	if pausado {
+		msg.Status = "paused"
+	}
+	if inicio, agendada := prazo.Abertura(); agendada {
+		msg.Abertura = inicio.Format(time.RFC3339)
+	}
+	anunciarOpcoes(&msg, validador)
+	return publishJSON(ch, msg, publishTimeout)
+}
+
+// Publica o heartbeat a cada intervalo enquanto o processo estiver no
+// ar, inclusive na carência após o prazo (DRAIN_GRACE).
+func enviarHeartbeats(ch Transport, prazo *Prazo, validador Validador, intervalo time.Duration) {
+	t := time.NewTicker(intervalo)
+	defer t.Stop()
+	for range t.C {
+		if err := enviarTempo(ch, prazo, validador); err != nil {
+			log.Printf("Erro ao enviar heartbeat: %v\n", err)
+		}
+	}
+}
+
+// Avisa que o prazo acabou e que os votos em trânsito ainda estão sendo
+// apurados (DRAIN_GRACE); clientes deixam de enviar votos.
+func enviarEncerrando(ch Transport, carencia time.Duration) error {
+	return publishJSON(ch, BroadcastMsg{
+		Tipo:   "server",
+		Status: "closing",
+		Prazo:  time.Now().Add(carencia).Format(time.RFC3339),
+	}, publishTimeout)
+}
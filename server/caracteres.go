@@ -0,0 +1,51 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Informa se o texto é UTF-8 válido e só tem caracteres imprimíveis
+// (o espaço ASCII incluído). Controles, quebras de linha e caracteres
+// invisíveis ficam de fora: vindos de um voto, iriam parar no log, no
+// terminal dos clientes e nas exportações CSV.
+func textoImprimivel(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Nome do primeiro campo do voto com caracteres inválidos, ou "".
+func campoInvalido(v Voto) string {
+	if !textoImprimivel(v.UserID) {
+		return "userId"
+	}
+	if !textoImprimivel(v.Option) {
+		return "opcao"
+	}
+	for _, op := range v.Options {
+		if !textoImprimivel(op) {
+			return "opcoes"
+		}
+	}
+	for _, op := range v.Ranking {
+		if !textoImprimivel(op) {
+			return "ranking"
+		}
+	}
+	if !textoImprimivel(v.Pow) {
+		return "pow"
+	}
+	for _, id := range v.DelegateFor {
+		if !textoImprimivel(id) {
+			return "delegateFor"
+		}
+	}
+	return ""
+}
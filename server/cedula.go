@@ -0,0 +1,44 @@
+package main
+
+//
+// Votos anônimos deduplicados por ballotHash (ALLOW_BALLOT_HASH).
+//
+// Em enquetes sem UserID estável, o cliente envia um hash gerado no
+// dispositivo e o servidor usa "cedula:<hash>" no lugar do UserID: a
+// mesma cédula só é contada uma vez, com as mesmas regras de revoto,
+// seq e nonce de um usuário identificado.
+//
+// Trocas de privacidade:
+//   - A chave aparece onde o UserID apareceria: confirmações e erros no
+//     broadcast (visíveis a todos os clientes conectados), log do worker,
+//     VOTE_LOG, AUDIT_STREAM e o comando "audit". Um hash derivado só do
+//     dispositivo identifica o aparelho em todas as votações que usarem o
+//     mesmo cálculo; o cliente deve misturar algo da votação (o cliente
+//     de linha de comando usa o prefixo das exchanges) para que cédulas de
+//     votações diferentes não possam ser ligadas entre si.
+//   - O servidor não tem como verificar o hash. Quem gera hashes novos
+//     vota quantas vezes quiser; a deduplicação só barra reenvios óbvios
+//     do mesmo dispositivo. Por isso fica desligada por padrão e não serve
+//     para votações com lista de eleitores (cédulas nunca estão na lista).
+//
+
+// Prefixo da chave de deduplicação de uma cédula anônima.
+const prefixoCedula = "cedula:"
+
+// Aceita ballotHash em votos sem UserID (ALLOW_BALLOT_HASH).
+var aceitarCedulas bool
+
+// Só votos sem usuário (vazio ou "anonymous") usam a cédula; com UserID,
+// a deduplicação continua pelo usuário.
+func anonimo(v Voto) bool {
+	return v.UserID == "" || v.UserID == "anonymous"
+}
+
+// Troca o UserID de um voto anônimo pela chave da cédula, quando
+// habilitado e o cliente enviou ballotHash.
+func identificarCedula(v Voto) Voto {
+	if aceitarCedulas && v.BallotHash != "" && anonimo(v) {
+		v.UserID = prefixoCedula + v.BallotHash
+	}
+	return v
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+//
+// Linha de comando: "server run [flags]".
+//
+// Cada flag corresponde a uma variável de ambiente e tem o valor dela como
+// padrão; uma flag passada na linha de comando sobrescreve a variável
+// antes de o restante do main lê-la. Sem subcomando, vale "run", para que
+// "server -standalone" continue funcionando.
+//
+// Com -config, as chaves do arquivo JSON (os nomes das flags) preenchem
+// as variáveis ainda não definidas. Precedência: flag > variável de
+// ambiente > arquivo > padrão do servidor.
+//
+
+// Flag de linha de comando e a variável de ambiente que ela substitui.
+type opcaoCLI struct {
+	flag  string
+	env   string
+	ajuda string
+}
+
+var opcoesCLI = []opcaoCLI{
+	{"options", "VOTING_OPTIONS", "opções da votação: lista (A,B,C) ou intervalo (RANGE:1-10)"},
+	{"timeout", "VOTING_TIMEOUT", "duração da votação (ex.: 180s)"},
+	{"start", "VOTING_START", "abertura agendada, em RFC3339"},
+	{"idle-timeout", "IDLE_TIMEOUT", "encerra a votação após esse tempo sem votos aceitos (ex.: 5m)"},
+	{"options-file", "OPTIONS_FILE", "arquivo JSON com as opções da votação (chave, rótulo, vagas e cor)"},
+	{"option-labels", "OPTION_LABELS", "rótulos de exibição por opção (ex.: A:Apple,B:Banana)"},
+	{"option-colors", "OPTION_COLORS", "cores de exibição por opção, em #rrggbb (ex.: A:#ff0000)"},
+	{"workers", "WORKERS", "workers consumindo a fila de votos"},
+	{"prefetch", "PREFETCH", "votos entregues a cada worker antes do ack"},
+	{"prefetch-auto", "PREFETCH_AUTO", "ajusta o prefetch sozinho dentro da faixa min:max (ex.: 10:500)"},
+	{"prefetch-auto-interval", "PREFETCH_AUTO_INTERVAL", "intervalo entre os ajustes do prefetch"},
+	{"vote-bindings", "VOTE_BINDINGS", "outras exchanges ligadas à fila de votos (exchange:rota[:tipo],...)"},
+	{"max-selections", "MAX_SELECTIONS", "opções por voto"},
+	{"tally", "TALLY", "modo de apuração: plurality, approval ou irv"},
+	{"caps", "CAPS", "vagas por opção (ex.: A:50,B:100)"},
+	{"max-voters", "MAX_VOTERS", "máximo de votantes distintos (0 = sem limite)"},
+	{"pow-difficulty", "POW_DIFFICULTY", "bits zero exigidos na prova de trabalho de cada voto (0 desliga)"},
+	{"allow-revote", "ALLOW_REVOTE", "aceita a troca de voto (true/false)"},
+	{"voter-identity", "VOTER_IDENTITY", "origem do UserID: payload (corpo do voto) ou cert (user_id validado pelo broker)"},
+	{"allow-ballot-hash", "ALLOW_BALLOT_HASH", "deduplica votos anônimos pelo ballotHash (true/false)"},
+	{"revote-cooldown", "REVOTE_COOLDOWN", "espera mínima entre dois votos do mesmo usuário"},
+	{"require-vote-seq", "REQUIRE_VOTE_SEQ", "exige seq crescente por usuário (true/false)"},
+	{"eligible-file", "ELIGIBLE_FILE", "arquivo com a lista de eleitores"},
+	{"eligible-ids", "ELIGIBLE_IDS", "lista de eleitores separada por vírgula"},
+	{"delegations-file", "DELEGATIONS_FILE", "arquivo JSON com as procurações (procurador -> representados)"},
+	{"tie-break", "TIE_BREAK", "desempate: none, first-to-reach, alphabetical ou random-seeded"},
+	{"tie-break-seed", "TIE_BREAK_SEED", "semente do desempate random-seeded"},
+	{"confirm-delay", "CONFIRM_DELAY", "atraso artificial antes da confirmação"},
+	{"max-msg-bytes", "MAX_MSG_BYTES", "tamanho máximo do corpo de um voto"},
+	{"ordered-per-user", "ORDERED_PER_USER", "votos do mesmo usuário sempre no mesmo worker (true/false)"},
+	{"vote-max-priority", "VOTE_MAX_PRIORITY", "prioridade máxima da fila de votos (0 desliga)"},
+	{"drain-grace", "DRAIN_GRACE", "carência para votos em trânsito no encerramento"},
+	{"publish-timeout", "PUBLISH_TIMEOUT", "prazo de publicação dos broadcasts"},
+	{"final-publish-timeout", "FINAL_PUBLISH_TIMEOUT", "prazo de publicação do resultado final"},
+	{"final-retries", "FINAL_RETRIES", "tentativas de entrega do resultado final"},
+	{"final-retry-initial", "FINAL_RETRY_INITIAL", "espera inicial entre tentativas do final"},
+	{"final-retry-max", "FINAL_RETRY_MAX", "espera máxima entre tentativas do final"},
+	{"heartbeat-interval", "HEARTBEAT_INTERVAL", "intervalo do heartbeat \"tempo\""},
+	{"hide-partials", "HIDE_PARTIALS", "não publica parciais (true/false)"},
+	{"partial-deltas", "PARTIAL_DELTAS", "parciais só com as opções que mudaram (true/false)"},
+	{"partial-full-every", "PARTIAL_FULL_EVERY", "uma parcial completa a cada N deltas"},
+	{"partial-queue-size", "PARTIAL_QUEUE_SIZE", "fila de parciais pendentes"},
+	{"codec", "CODEC", "codec dos broadcasts: json ou msgpack"},
+	{"compress-threshold", "COMPRESS_THRESHOLD", "comprime broadcasts acima de N bytes (0 desliga)"},
+	{"broadcast-mandatory", "BROADCAST_MANDATORY", "publica broadcasts com mandatory (true/false)"},
+	{"broadcast-langs", "BROADCAST_LANGS", "idiomas com rota própria no broadcast (ex.: pt-BR,en,es); vazio mantém o fanout"},
+	{"exchange-prefix", "EXCHANGE_PREFIX", "prefixo das exchanges e da fila"},
+	{"durable", "DURABLE", "exchanges e fila duráveis (true/false)"},
+	{"connection-name", "CONNECTION_NAME", "nome da conexão no painel do RabbitMQ"},
+	{"reconnect-initial", "RECONNECT_INITIAL", "espera inicial entre tentativas de conexão"},
+	{"reconnect-max", "RECONNECT_MAX", "espera máxima entre tentativas de conexão"},
+	{"reconnect-factor", "RECONNECT_FACTOR", "multiplicador da espera entre tentativas"},
+	{"bench-mode", "BENCH_MODE", "só conta os votos, sem confirmações, erros, parciais nem auditoria (true/false)"},
+	{"health-addr", "HEALTH_ADDR", "endereço HTTP de diagnóstico (ex.: :8080 ou unix:/caminho)"},
+	{"cors-origins", "CORS_ORIGINS", "origens aceitas pelo CORS das rotas HTTP (separadas por vírgula, * = todas)"},
+	{"audit-stream", "AUDIT_STREAM", "republica cada voto aceito na exchange de auditoria (true/false)"},
+	{"audit-queue", "AUDIT_QUEUE", "fila durável ligada à exchange de auditoria"},
+	{"vote-log", "VOTE_LOG", "arquivo JSON lines com cada voto aceito"},
+	{"vote-log-fsync", "VOTE_LOG_FSYNC", "fsync do log de votos: vote, batch:N, interval:D ou off"},
+	{"persistence-mode", "PERSISTENCE_MODE", "log de votos indisponível: strict (não sobe) ou lenient (segue em memória)"},
+	{"live-results-file", "LIVE_RESULTS_FILE", "arquivo JSON com a contagem ao vivo"},
+	{"live-results-interval", "LIVE_RESULTS_INTERVAL", "intervalo de gravação da contagem ao vivo"},
+	{"result-webhook", "RESULT_WEBHOOK", "URL que recebe o resultado final"},
+	{"kafka-brokers", "KAFKA_BROKERS", "brokers Kafka que recebem os votos aceitos e o final, separados por vírgula (exige -tags kafka)"},
+	{"kafka-topic", "KAFKA_TOPIC", "tópico Kafka dos eventos da votação"},
+	{"admin-files-dir", "ADMIN_FILES_DIR", "diretório em que audit -arquivo e dump-state gravam no servidor"},
+}
+
+// Variáveis sem flag: segredos e URLs com senha não devem aparecer na
+// lista de processos. Podem vir do ambiente ou do arquivo de -config.
+var somenteAmbiente = []opcaoCLI{
+	{"rabbitmq-urls", "RABBITMQ_URLS", "nós do broker, separados por vírgula"},
+	{"admin-token", "ADMIN_TOKEN", "token do canal de controle e do POST /snapshot"},
+	{"receipt-secret", "RECEIPT_SECRET", "segredo dos recibos HMAC"},
+	{"otel-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "coletor OTLP dos traces"},
+}
+
+// Opções do subcomando run que não vêm só de uma variável de ambiente.
+type Execucao struct {
+	arquivoOpcoes string
+	standalone    bool
+	// Arquivo de -config, vazio sem ele.
+	arquivoConfig string
+	// Imprime o resultado final em stdout antes de sair (-emit-result-stdout).
+	resultadoStdout bool
+	// Arquivo do "dump-state" de outro servidor (-restore-state).
+	arquivoEstado string
+}
+
+// Interpreta a linha de comando e aplica as flags passadas ao ambiente.
+// Sai com código 2 diante de um subcomando desconhecido.
+func lerLinhaDeComando(args []string) Execucao {
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			args = args[1:]
+		case "help":
+			usoGeral()
+			os.Exit(0)
+		default:
+			if args[0] != "" && args[0][0] != '-' {
+				fmt.Fprintf(os.Stderr, "Subcomando desconhecido: %s\n\n", args[0])
+				usoGeral()
+				os.Exit(2)
+			}
+		}
+	}
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	valores := make([]*string, len(opcoesCLI))
+	for i, o := range opcoesCLI {
+		valores[i] = fs.String(o.flag, os.Getenv(o.env), o.ajuda+" ("+o.env+")")
+	}
+	var e Execucao
+	fs.StringVar(&e.arquivoConfig, "config", "", "arquivo JSON com as configurações, chaves com os nomes das flags")
+	// Cliente interativo no mesmo processo, para demonstrações.
+	fs.BoolVar(&e.standalone, "standalone", false, "roda também um cliente interativo neste processo, na mesma conexão")
+	fs.StringVar(&e.arquivoEstado, "restore-state", "", "retoma a votação do arquivo gravado pelo comando dump-state de outro servidor")
+	fs.BoolVar(&e.resultadoStdout, "emit-result-stdout", false, "imprime o resultado final como uma linha JSON em stdout antes de sair (os logs seguem em stderr)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: server run [flags]")
+		fmt.Fprintln(os.Stderr, "\nCada flag sobrescreve a variável de ambiente entre parênteses, cujo valor é o padrão.\n\nFlags:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nSem flag (variável de ambiente ou chave do -config):")
+		for _, o := range somenteAmbiente {
+			fmt.Fprintf(os.Stderr, "  %s (%q): %s\n", o.env, o.flag, o.ajuda)
+		}
+		fmt.Fprintln(os.Stderr, "\nPrecedência: flag > variável de ambiente > -config > padrão.")
+	}
+	fs.Parse(args)
+	if e.resultadoStdout && e.standalone {
+		fmt.Fprintln(os.Stderr, "-emit-result-stdout e -standalone não podem ser usados juntos: o cliente standalone escreve em stdout.")
+		os.Exit(2)
+	}
+
+	// Só as flags passadas sobrescrevem o ambiente; as demais já têm o
+	// valor dele.
+	indice := map[string]int{}
+	for i, o := range opcoesCLI {
+		indice[o.flag] = i
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if i, ok := indice[f.Name]; ok {
+			os.Setenv(opcoesCLI[i].env, *valores[i])
+		}
+	})
+
+	// O arquivo só preenche o que flag e ambiente deixaram em aberto.
+	if e.arquivoConfig != "" {
+		if err := aplicarArquivoConfig(e.arquivoConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro no arquivo de configuração: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	e.arquivoOpcoes = os.Getenv("OPTIONS_FILE")
+	return e
+}
+
+func usoGeral() {
+	fmt.Fprintln(os.Stderr, "Uso: server [run] [flags]")
+	fmt.Fprintln(os.Stderr, "\nSubcomandos:")
+	fmt.Fprintln(os.Stderr, "  run   inicia a votação (padrão)")
+	fmt.Fprintln(os.Stderr, "  help  mostra esta ajuda")
+	fmt.Fprintln(os.Stderr, "\nUse \"server run --help\" para ver todas as flags.")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+//
+// Codificação das mensagens de voto e de broadcast (CODEC).
+//
+// O servidor publica no codec configurado e decodifica cada mensagem
+// recebida pelo ContentType dela, então clientes JSON e MessagePack
+// convivem. O MessagePack usa as mesmas chaves das tags json.
+//
+
+// Codec serializa as mensagens trocadas pelo AMQP.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+const (
+	tipoJSON    = "application/json"
+	tipoMsgpack = "application/msgpack"
+)
+
+type codecJSON struct{}
+
+func (codecJSON) ContentType() string                { return tipoJSON }
+func (codecJSON) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (codecJSON) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type codecMsgpack struct{}
+
+func (codecMsgpack) ContentType() string { return tipoMsgpack }
+
+func (codecMsgpack) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (codecMsgpack) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// Codec dos broadcasts publicados pelo servidor.
+var codecEnvio Codec = codecJSON{}
+
+// Codec pelo nome configurado em CODEC.
+func novoCodec(nome string) (Codec, error) {
+	switch nome {
+	case "", "json":
+		return codecJSON{}, nil
+	case "msgpack":
+		return codecMsgpack{}, nil
+	}
+	return nil, fmt.Errorf("codec desconhecido %q (use json ou msgpack)", nome)
+}
+
+// Codec de uma mensagem recebida; sem ContentType conhecido, JSON.
+func codecDoTipo(contentType string) Codec {
+	switch contentType {
+	case tipoMsgpack, "application/x-msgpack":
+		return codecMsgpack{}
+	}
+	return codecJSON{}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lê o arquivo de -config e define as variáveis de ambiente que ainda não
+// têm valor. As chaves são os nomes das flags (ex.: "timeout", "caps") e
+// as de somenteAmbiente (ex.: "rabbitmq-urls"). Chave desconhecida ou
+// valor de tipo não suportado é erro, antes de qualquer variável mudar.
+func aplicarArquivoConfig(caminho string) error {
+	dados, err := os.ReadFile(caminho)
+	if err != nil {
+		return err
+	}
+	var bruto map[string]any
+	dec := json.NewDecoder(bytes.NewReader(dados))
+	dec.UseNumber()
+	if err := dec.Decode(&bruto); err != nil {
+		var sintaxe *json.SyntaxError
+		if errors.As(err, &sintaxe) {
+			return fmt.Errorf("%s, linha %d: %v", caminho, linhaDoOffset(dados, sintaxe.Offset), err)
+		}
+		return fmt.Errorf("%s: %v", caminho, err)
+	}
+
+	envDaChave := map[string]string{}
+	for _, o := range append(append([]opcaoCLI{}, opcoesCLI...), somenteAmbiente...) {
+		envDaChave[o.flag] = o.env
+	}
+
+	// Valida tudo primeiro, em ordem alfabética para a mensagem ser estável.
+	chaves := make([]string, 0, len(bruto))
+	for k := range bruto {
+		chaves = append(chaves, k)
+	}
+	sort.Strings(chaves)
+	valores := map[string]string{}
+	var desconhecidas []string
+	for _, k := range chaves {
+		if _, ok := envDaChave[k]; !ok {
+			desconhecidas = append(desconhecidas, k)
+			continue
+		}
+		v, err := valorConfig(bruto[k])
+		if err != nil {
+			return fmt.Errorf("%s: chave %q: %v", caminho, k, err)
+		}
+		valores[k] = v
+	}
+	if len(desconhecidas) > 0 {
+		return fmt.Errorf("%s: chaves desconhecidas: %s (veja \"server run --help\")", caminho, strings.Join(desconhecidas, ", "))
+	}
+
+	for k, v := range valores {
+		if _, definida := os.LookupEnv(envDaChave[k]); !definida {
+			os.Setenv(envDaChave[k], v)
+		}
+	}
+	return nil
+}
+
+// Converte um valor do JSON no texto da variável de ambiente: listas
+// viram "a,b" e objetos "chave:valor" (como em CAPS), em ordem alfabética.
+func valorConfig(v any) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case json.Number:
+		return x.String(), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case []any:
+		partes := make([]string, len(x))
+		for i, item := range x {
+			s, err := escalarConfig(item)
+			if err != nil {
+				return "", err
+			}
+			partes[i] = s
+		}
+		return strings.Join(partes, ","), nil
+	case map[string]any:
+		chaves := make([]string, 0, len(x))
+		for k := range x {
+			chaves = append(chaves, k)
+		}
+		sort.Strings(chaves)
+		partes := make([]string, len(chaves))
+		for i, k := range chaves {
+			s, err := escalarConfig(x[k])
+			if err != nil {
+				return "", err
+			}
+			partes[i] = k + ":" + s
+		}
+		return strings.Join(partes, ","), nil
+	case nil:
+		return "", fmt.Errorf("valor nulo")
+	}
+	return "", fmt.Errorf("tipo não suportado")
+}
+
+// Itens de listas e objetos: só texto, número ou booleano.
+func escalarConfig(v any) (string, error) {
+	switch v.(type) {
+	case string, json.Number, bool:
+		return valorConfig(v)
+	}
+	return "", fmt.Errorf("listas e objetos só aceitam texto, número ou booleano")
+}
+
+func linhaDoOffset(dados []byte, offset int64) int {
+	return bytes.Count(dados[:min(int(offset), len(dados))], []byte("\n")) + 1
+}
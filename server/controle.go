@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+//
+// Canal de controle administrativo.
+//
+// Os comandos chegam pela exchange direct "<prefixo>.controle" com a
+// routing key "comando" e precisam trazer o token compartilhado
+// (ADMIN_TOKEN). Respostas são publicadas na fila indicada em ReplyTo.
+//
+
+// Comando administrativo recebido pelo canal de controle.
+type Comando struct {
+	Cmd     string `json:"cmd"`
+	Token   string `json:"token"`
+	Arquivo string `json:"arquivo,omitempty"`
+	// Formato do "export": json (padrão) ou csv.
+	Formato string `json:"format,omitempty"`
+	// Segundos somados ou tirados do prazo no "extend" e no "shorten".
+	Segundos int `json:"seconds,omitempty"`
+	// No "dump-state", encerra o servidor logo após gravar (ver estado.go).
+	Parar bool `json:"stop,omitempty"`
+}
+
+// Resposta a um comando administrativo.
+type RespostaControle struct {
+	Cmd      string            `json:"cmd"`
+	Ok       bool              `json:"ok"`
+	Mensagem string            `json:"mensagem,omitempty"`
+	Parte    int               `json:"parte,omitempty"`
+	Total    int               `json:"total,omitempty"`
+	Votos    map[string]string `json:"votos,omitempty"`
+
+	// Campos do "export".
+	Resultado map[string]int `json:"resultado,omitempty"`
+	Vencedor  string         `json:"vencedor,omitempty"`
+	Final     bool           `json:"final,omitempty"`
+	Corpo     string         `json:"corpo,omitempty"`
+}
+
+// Quantidade de votos por mensagem de resposta da auditoria.
+const auditChunk = 5000
+
+// Controle reúne o estado da votação acessível pelos comandos.
+type Controle struct {
+	ch       Transport
+	token    string
+	apuracao *Apuracao
+	prazo    *Prazo
+	// Duração de uma votação nova, usada pelo "reset".
+	timeout time.Duration
+	// Diretório dos arquivos do "audit" e do "dump-state"
+	// (ADMIN_FILES_DIR); vazio recusa a gravação em arquivo.
+	diretorio string
+}
+
+// Processa os comandos recebidos até o canal de entrega ser fechado.
+func (ct *Controle) tratarComandos(cmds <-chan amqp.Delivery) {
+	ch := ct.ch
+	for d := range cmds {
+		var c Comando
+		if err := json.Unmarshal(d.Body, &c); err != nil {
+			log.Printf("[Controle] Comando inválido: %v\n", err)
+			continue
+		}
+
+		// Comparação em tempo constante para não vazar o token.
+		if subtle.ConstantTimeCompare([]byte(c.Token), []byte(ct.token)) != 1 {
+			log.Printf("[Controle] Comando %q rejeitado: token inválido\n", c.Cmd)
+			responderControle(ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "Token inválido."})
+			continue
+		}
+
+		log.Printf("[Controle] Comando recebido: %s\n", c.Cmd)
+
+		switch c.Cmd {
+		case "audit":
+			ct.auditar(d, c)
+		case "pause":
+			ct.pausar(d, c)
+		case "resume":
+			ct.retomar(d, c)
+		case "close":
+			ct.encerrar(d, c)
+		case "reset":
+			ct.reiniciar(d, c)
+		case "extend":
+			ct.ajustarPrazo(d, c, time.Duration(c.Segundos)*time.Second)
+		case "shorten":
+			ct.ajustarPrazo(d, c, -time.Duration(c.Segundos)*time.Second)
+		case "export":
+			ct.exportar(d, c)
+		case "snapshot":
+			ct.instantaneo(d, c)
+		case "dump-state":
+			ct.gravarEstado(d, c)
+		default:
+			responderControle(ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "Comando desconhecido."})
+		}
+	}
+}
+
+// Envia o mapa completo de votos por usuário, no arquivo pedido ou em
+// partes para a fila de resposta.
+func (ct *Controle) auditar(d amqp.Delivery, c Comando) {
+	ch := ct.ch
+
+	// A cópia é a única parte feita sob o Lock; serialização e envio
+	// acontecem fora dele para não travar os workers.
+	snapshot := ct.apuracao.copiaVotos()
+
+	if c.Arquivo != "" {
+		caminho, err := ct.caminhoArquivo(c.Arquivo)
+		if err == nil {
+			err = escreverAuditoria(caminho, snapshot)
+		}
+		if err != nil {
+			log.Printf("[Controle] Erro ao gravar auditoria: %v\n", err)
+			responderControle(ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: err.Error()})
+			return
+		}
+		responderControle(ch, d, RespostaControle{
+			Cmd:      c.Cmd,
+			Ok:       true,
+			Mensagem: fmt.Sprintf("%d votos gravados em %s.", len(snapshot), caminho),
+		})
+		return
+	}
+
+	total := (len(snapshot) + auditChunk - 1) / auditChunk
+	if total == 0 {
+		total = 1
+	}
+
+	parte := 1
+	chunk := make(map[string]string, auditChunk)
+	enviarParte := func() {
+		responderControle(ch, d, RespostaControle{
+			Cmd:   c.Cmd,
+			Ok:    true,
+			Parte: parte,
+			Total: total,
+			Votos: chunk,
+		})
+		parte++
+		chunk = make(map[string]string, auditChunk)
+	}
+
+	for k, v := range snapshot {
+		chunk[k] = v
+		if len(chunk) == auditChunk {
+			enviarParte()
+		}
+	}
+	if len(chunk) > 0 || parte == 1 {
+		enviarParte()
+	}
+}
+
+// Suspende a votação: os votos passam a ser rejeitados e o prazo para
+// de correr até o comando "resume".
+func (ct *Controle) pausar(d amqp.Delivery, c Comando) {
+	if !ct.prazo.Pausar() {
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "A votação já está pausada ou encerrada."})
+		return
+	}
+
+	log.Println("[Controle] Votação pausada.")
+	if err := enviarPausa(ct.ch); err != nil {
+		log.Printf("[Controle] Erro ao anunciar pausa: %v\n", err)
+	}
+	responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Ok: true, Mensagem: "Votação pausada."})
+}
+
+// Retoma a votação com o tempo que restava antes da pausa.
+func (ct *Controle) retomar(d amqp.Delivery, c Comando) {
+	fim, ok := ct.prazo.Retomar()
+	if !ok {
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "A votação não está pausada."})
+		return
+	}
+
+	log.Printf("[Controle] Votação retomada; encerra às %s.\n", fim.Format(time.TimeOnly))
+	if err := enviarRetomada(ct.ch, fim); err != nil {
+		log.Printf("[Controle] Erro ao anunciar retomada: %v\n", err)
+	}
+	responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Ok: true, Mensagem: "Votação retomada."})
+}
+
+// Encerra a votação agora; o resultado final segue o mesmo caminho do
+// encerramento por tempo.
+func (ct *Controle) encerrar(d amqp.Delivery, c Comando) {
+	log.Println("[Controle] Encerramento da votação solicitado.")
+	responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Ok: true, Mensagem: "Votação encerrada."})
+	ct.prazo.Encerrar()
+}
+
+// Descarta os votos e recomeça a votação com o prazo completo.
+func (ct *Controle) reiniciar(d amqp.Delivery, c Comando) {
+	fim, ok := ct.prazo.Reiniciar(ct.timeout)
+	if !ok {
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "A votação já foi encerrada."})
+		return
+	}
+	zerada, quando := ct.apuracao.reiniciar()
+	if logVotos != nil {
+		if err := logVotos.registrarReset(quando); err != nil {
+			log.Printf("[Controle] Erro ao gravar reset no log de votos: %v\n", err)
+		}
+	}
+
+	log.Printf("[Controle] Votação reiniciada; encerra às %s.\n", fim.Format(time.TimeOnly))
+	if err := enviarOpcoes(ct.ch, ct.apuracao.validador); err != nil {
+		log.Printf("[Controle] Erro ao anunciar as opções: %v\n", err)
+	}
+	if err := enviarRetomada(ct.ch, fim); err != nil {
+		log.Printf("[Controle] Erro ao anunciar o novo prazo: %v\n", err)
+	}
+	// Sem a parcial zerada, os clientes continuam mostrando a contagem de
+	// antes do reset; o organizador precisa saber para pedir um snapshot.
+	mensagem := "Votação reiniciada."
+	if err := enviarParcial(ct.ch, zerada); err != nil {
+		log.Printf("[Controle] Erro ao publicar a parcial zerada: %v\n", err)
+		mensagem = fmt.Sprintf("Votação reiniciada, mas a parcial zerada não foi publicada (%v); use \"snapshot\".", err)
+	}
+	responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Ok: true, Mensagem: mensagem})
+}
+
+// Estende ou encurta o tempo restante e anuncia o novo prazo aos
+// clientes, que atualizam a contagem regressiva.
+func (ct *Controle) ajustarPrazo(d amqp.Delivery, c Comando, delta time.Duration) {
+	if c.Segundos <= 0 {
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "Informe \"seconds\" maior que zero."})
+		return
+	}
+	fim, ok := ct.prazo.Ajustar(delta)
+	if !ok {
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "A votação ainda não abriu ou já foi encerrada."})
+		return
+	}
+
+	log.Printf("[Controle] Prazo ajustado em %v; encerra às %s.\n", delta, fim.Format(time.TimeOnly))
+	if err := enviarNovoPrazo(ct.ch, fim); err != nil {
+		log.Printf("[Controle] Erro ao anunciar o novo prazo: %v\n", err)
+	}
+	responderControle(ct.ch, d, RespostaControle{
+		Cmd:      c.Cmd,
+		Ok:       true,
+		Mensagem: fmt.Sprintf("Prazo ajustado; encerra às %s.", fim.Format(time.TimeOnly)),
+	})
+}
+
+// Publica agora uma parcial completa, para atualizar clientes que
+// entraram depois do último voto.
+func (ct *Controle) instantaneo(d amqp.Delivery, c Comando) {
+	if err := enviarInstantaneo(ct.ch, ct.apuracao); err != nil {
+		log.Printf("[Controle] Erro ao publicar snapshot: %v\n", err)
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: err.Error()})
+		return
+	}
+	responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Ok: true, Mensagem: "Parcial publicada."})
+}
+
+// Grava o estado completo no arquivo pedido. Com "stop", o stateMu fica
+// travado até a saída do processo: nenhum voto é contado depois da
+// gravação, e os que estavam com os workers voltam para a fila. Antes
+// de sair, o servidor avisa os clientes, como no encerramento por sinal.
+func (ct *Controle) gravarEstado(d amqp.Delivery, c Comando) {
+	if c.Arquivo == "" {
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "Informe \"arquivo\"."})
+		return
+	}
+	caminho, err := ct.caminhoArquivo(c.Arquivo)
+	if err != nil {
+		log.Printf("[Controle] Erro ao gravar o estado: %v\n", err)
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: err.Error()})
+		return
+	}
+
+	stateMu.Lock()
+	estado := ct.apuracao.estadoTravado()
+	if !c.Parar {
+		stateMu.Unlock()
+	}
+	if err := gravarEstado(caminho, estado); err != nil {
+		if c.Parar {
+			stateMu.Unlock()
+		}
+		log.Printf("[Controle] Erro ao gravar o estado: %v\n", err)
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: err.Error()})
+		return
+	}
+
+	log.Printf("[Controle] Estado gravado em %s (%d votos).\n", caminho, len(estado.Votos))
+	if !c.Parar {
+		responderControle(ct.ch, d, RespostaControle{
+			Cmd:      c.Cmd,
+			Ok:       true,
+			Mensagem: fmt.Sprintf("Estado com %d votos gravado em %s.", len(estado.Votos), caminho),
+		})
+		return
+	}
+	responderControle(ct.ch, d, RespostaControle{
+		Cmd:      c.Cmd,
+		Ok:       true,
+		Mensagem: fmt.Sprintf("Estado com %d votos gravado em %s; servidor encerrando para a transferência.", len(estado.Votos), caminho),
+	})
+	marcarEncerramento(MotivoTransferencia)
+	avisarDesligamento(ct.ch, MotivoTransferencia)
+	sair(MotivoTransferencia, 0)
+}
+
+// Caminho de um arquivo pedido pelo "audit" ou pelo "dump-state": um
+// caminho relativo dentro do ADMIN_FILES_DIR, sem "..", para que quem tem
+// o token não grave em qualquer lugar do disco do servidor.
+func (ct *Controle) caminhoArquivo(nome string) (string, error) {
+	if ct.diretorio == "" {
+		return "", errors.New("gravação em arquivo desabilitada: defina ADMIN_FILES_DIR no servidor")
+	}
+	if !filepath.IsLocal(nome) {
+		return "", fmt.Errorf("arquivo %q fora de ADMIN_FILES_DIR: use um caminho relativo, sem \"..\"", nome)
+	}
+	return filepath.Join(ct.diretorio, nome), nil
+}
+
+// Grava o mapa de votos como um objeto JSON, entrada por entrada, sem
+// montar o documento inteiro em memória.
+func escreverAuditoria(caminho string, votos map[string]string) error {
+	f, err := os.Create(caminho)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.WriteString("{")
+	primeiro := true
+	for k, v := range votos {
+		if !primeiro {
+			w.WriteString(",")
+		}
+		primeiro = false
+		key, _ := json.Marshal(k)
+		val, _ := json.Marshal(v)
+		w.Write(key)
+		w.WriteString(":")
+		w.Write(val)
+	}
+	w.WriteString("}\n")
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Publica a resposta na fila ReplyTo do comando, se houver.
+func responderControle(ch Transport, d amqp.Delivery, resp RespostaControle) {
+	if d.ReplyTo == "" {
+		return
+	}
+
+	amqpMu.Lock()
+	defer amqpMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	body, _ := json.Marshal(resp)
+
+	err := ch.PublishWithContext(
+		ctx,
+		"", // Exchange padrão: entrega direto na fila ReplyTo.
+		d.ReplyTo,
+		true, // mandatory: se a fila de resposta sumiu, o broker devolve.
+		false,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: d.CorrelationId,
+			Body:          body,
+		},
+	)
+	if err != nil {
+		log.Printf("[Controle] Erro ao responder comando: %v\n", err)
+	}
+}
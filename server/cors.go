@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Origens aceitas em CORS_ORIGINS, separadas por vírgula; "*" aceita
+// qualquer uma. Vazio: nenhum cabeçalho CORS (só a mesma origem).
+func lerOrigensCORS(spec string) []string {
+	var origens []string
+	for _, o := range strings.Split(spec, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origens = append(origens, strings.TrimSuffix(o, "/"))
+		}
+	}
+	return origens
+}
+
+// Envolve as rotas HTTP com os cabeçalhos Access-Control-Allow-* para
+// dashboards hospedados em outra origem, respondendo o preflight OPTIONS
+// antes de chegar ao mux (que recusaria o método).
+func comCORS(origens []string, h http.Handler) http.Handler {
+	if len(origens) == 0 {
+		return h
+	}
+	todas := slices.Contains(origens, "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origem := r.Header.Get("Origin")
+		permitida := origem != "" && (todas || slices.Contains(origens, origem))
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if permitida {
+			// A resposta muda com a Origin; caches não podem misturar.
+			w.Header().Add("Vary", "Origin")
+			if todas {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origem)
+			}
+		}
+		if !preflight {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !permitida {
+			http.Error(w, "origem não permitida", http.StatusForbidden)
+			return
+		}
+		// Authorization é usado pelo POST /snapshot.
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
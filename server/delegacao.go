@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//
+// Voto por procuração (DELEGATIONS_FILE).
+//
+// O arquivo diz quem pode votar por quem: {"proc": ["ana", "bruno"]}. Um
+// voto de "proc" com "delegateFor": ["ana"] vira duas cédulas, a do
+// próprio proc e a de ana, cada uma contada, confirmada e recusada por
+// conta própria, como se ana tivesse votado na mesma opção.
+//
+// Regras:
+//   - Um representado fora da procuração do remetente recusa o voto
+//     inteiro com DELEGATION_DENIED, antes de qualquer cédula contar.
+//   - A cédula de quem já votou é recusada com ALREADY_VOTED, mesmo com
+//     ALLOW_REVOTE: a procuração não substitui um voto direto.
+//   - Depois da cédula por procuração, o representado não vota mais
+//     diretamente (DELEGATED).
+//
+
+// Procurações: procurador -> representados. Somente leitura.
+type Delegacoes map[string]map[string]bool
+
+// Lê o arquivo de procurações; sem caminho, devolve nil (desligado).
+func carregarDelegacoes(caminho string) (Delegacoes, error) {
+	if caminho == "" {
+		return nil, nil
+	}
+	dados, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, err
+	}
+	var bruto map[string][]string
+	if err := json.Unmarshal(dados, &bruto); err != nil {
+		return nil, fmt.Errorf("%s: %v", caminho, err)
+	}
+	d := Delegacoes{}
+	for procurador, representados := range bruto {
+		procurador = strings.TrimSpace(procurador)
+		for _, id := range representados {
+			id = strings.TrimSpace(id)
+			if id == "" || id == procurador {
+				return nil, fmt.Errorf("%s: representado inválido %q para %q", caminho, id, procurador)
+			}
+			if d[procurador] == nil {
+				d[procurador] = map[string]bool{}
+			}
+			d[procurador][id] = true
+		}
+	}
+	return d, nil
+}
+
+// Quantidade de procurações, para o log e o /config.
+func (d Delegacoes) total() int {
+	n := 0
+	for _, r := range d {
+		n += len(r)
+	}
+	return n
+}
+
+// Separa um voto nas cédulas que ele representa: a do remetente e uma por
+// representado, sem repetições. Devolve o código de erro quando alguma
+// procuração não existe.
+func (d Delegacoes) cedulas(v Voto) ([]Voto, string) {
+	if len(v.DelegateFor) == 0 {
+		return []Voto{v}, ""
+	}
+	cedulas := []Voto{v}
+	vistos := map[string]bool{}
+	for _, id := range v.DelegateFor {
+		if !d[v.UserID][id] {
+			return nil, CodDelegacaoNegada
+		}
+		if vistos[id] {
+			continue
+		}
+		vistos[id] = true
+
+		c := v
+		c.UserID = id
+		c.DelegateFor = nil
+		c.procurador = v.UserID
+		// Nonce próprio por cédula, para a retentativa do voto inteiro
+		// reconfirmar cada uma sem colidir com a do remetente.
+		if v.Nonce != "" {
+			c.Nonce = v.Nonce + ":" + id
+		}
+		cedulas = append(cedulas, c)
+	}
+	return cedulas, ""
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//
+// Parciais em modo delta (PARTIAL_DELTAS).
+//
+// Cada parcial leva uma sequência e só as opções que mudaram. Um cliente
+// que percebe uma lacuna na sequência publica uma mensagem com routing
+// key "snapshot" na exchange de votos e recebe uma parcial completa.
+//
+
+// Routing key dos pedidos de parcial completa.
+const rotaSnapshot = "snapshot"
+
+// Intervalo mínimo entre duas parciais completas pedidas por clientes:
+// vários clientes com a mesma lacuna recebem a mesma resposta.
+const intervaloSnapshot = time.Second
+
+// Horário (UnixNano) da última parcial completa enviada a pedido.
+var ultimoSnapshot atomic.Int64
+
+// Variação de cada opção entre duas contagens; opções sem mudança ficam
+// de fora. Uma variação negativa vem de um revoto.
+func diferencaContagem(antes, depois map[string]int) map[string]int {
+	delta := map[string]int{}
+	for op, n := range depois {
+		if d := n - antes[op]; d != 0 {
+			delta[op] = d
+		}
+	}
+	for op, n := range antes {
+		if _, ok := depois[op]; !ok && n != 0 {
+			delta[op] = -n
+		}
+	}
+	return delta
+}
+
+// Informa se um pedido de parcial completa deve ser atendido agora.
+func liberarSnapshot(agora time.Time) bool {
+	anterior := ultimoSnapshot.Load()
+	if agora.UnixNano()-anterior < int64(intervaloSnapshot) {
+		return false
+	}
+	return ultimoSnapshot.CompareAndSwap(anterior, agora.UnixNano())
+}
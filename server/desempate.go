@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+//
+// Definição do vencedor e políticas de desempate.
+//
+
+// Valor de "vencedor" quando há empate e nenhuma política o resolve.
+const empate = "empate"
+
+// Políticas de desempate aceitas em TIE_BREAK.
+const (
+	DesempateNenhum       = "none"
+	DesempatePrimeiro     = "first-to-reach"
+	DesempateAlfabetico   = "alphabetical"
+	DesempateAleatorioSem = "random-seeded"
+)
+
+// Confere se a política configurada é conhecida.
+func validarDesempate(politica string) error {
+	switch politica {
+	case DesempateNenhum, DesempatePrimeiro, DesempateAlfabetico, DesempateAleatorioSem:
+		return nil
+	}
+	return fmt.Errorf("política de desempate desconhecida %q", politica)
+}
+
+// Retorna a opção vencedora. Sem votos, retorna "". Em caso de empate,
+// aplica a política:
+//   - first-to-reach: vence quem chegou primeiro à contagem final, ou
+//     seja, a opção empatada cujo último voto é o mais antigo;
+//   - alphabetical: vence a primeira em ordem alfabética;
+//   - random-seeded: sorteio reprodutível a partir da semente;
+//   - none: retorna "empate".
+func definirVencedor(contagem map[string]int, ultimoVoto map[string]time.Time, politica string, semente int64) string {
+	maior := 0
+	var empatadas []string
+	for op, n := range contagem {
+		switch {
+		case n > maior:
+			maior = n
+			empatadas = []string{op}
+		case n == maior && n > 0:
+			empatadas = append(empatadas, op)
+		}
+	}
+
+	if maior == 0 {
+		return ""
+	}
+	if len(empatadas) == 1 {
+		return empatadas[0]
+	}
+
+	// Ordena para que o resultado não dependa da ordem do mapa.
+	sort.Strings(empatadas)
+
+	switch politica {
+	case DesempatePrimeiro:
+		vencedor := empatadas[0]
+		for _, op := range empatadas[1:] {
+			if ultimoVoto[op].Before(ultimoVoto[vencedor]) {
+				vencedor = op
+			}
+		}
+		return vencedor
+	case DesempateAlfabetico:
+		return empatadas[0]
+	case DesempateAleatorioSem:
+		r := rand.New(rand.NewSource(semente))
+		return empatadas[r.Intn(len(empatadas))]
+	}
+	return empate
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"hash/fnv"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Distribui as mensagens entre filas por worker de acordo com o hash do
+// UserID, garantindo que os votos de um mesmo usuário sejam processados
+// sempre pelo mesmo worker, na ordem em que chegaram.
+func despacharPorUsuario(msgs <-chan amqp.Delivery, numWorkers, maxBytes int) []chan amqp.Delivery {
+	filas := make([]chan amqp.Delivery, numWorkers)
+	for i := range filas {
+		filas[i] = make(chan amqp.Delivery, 1)
+	}
+
+	go func() {
+		for msg := range msgs {
+			h := fnv.New32a()
+			h.Write([]byte(usuarioDoDespacho(msg, maxBytes)))
+			filas[h.Sum32()%uint32(numWorkers)] <- msg
+		}
+
+		for _, f := range filas {
+			close(f)
+		}
+	}()
+
+	return filas
+}
+
+// Só o UserID interessa aqui; mensagens inválidas seguem para um worker
+// qualquer, que registra o erro ao interpretar. Corpos acima de
+// MAX_MSG_BYTES nem são decodificados: o worker os descarta sem ler, e
+// decodificá-los aqui pesaria na única goroutine do despacho.
+//
+// Com VOTER_IDENTITY=cert, vale o user_id do certificado, como no worker:
+// o userId do corpo é do cliente, que poderia trocá-lo a cada voto para
+// espalhar os votos de uma mesma identidade entre os workers.
+func usuarioDoDespacho(msg amqp.Delivery, maxBytes int) string {
+	if identidadePeloBroker {
+		return msg.UserId
+	}
+	if maxBytes > 0 && len(msg.Body) > maxBytes {
+		return ""
+	}
+	var v struct {
+		UserID string `json:"userId"`
+	}
+	codecDoTipo(msg.ContentType).Unmarshal(msg.Body, &v)
+	return v.UserID
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Carrega a lista de eleitores habilitados a partir de um arquivo (um ID
+// por linha, linhas com # são comentários) e/ou de uma lista separada por
+// vírgulas. Retorna nil quando nenhuma fonte foi configurada, o que
+// mantém a votação aberta a qualquer UserID.
+//
+// O mapa é montado uma vez na inicialização e só é lido depois, por isso
+// os workers o consultam sem passar pelo stateMu.
+func carregarElegiveis(arquivo, lista string) (map[string]bool, error) {
+	if arquivo == "" && lista == "" {
+		return nil, nil
+	}
+
+	elegiveis := map[string]bool{}
+
+	for _, id := range strings.Split(lista, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			elegiveis[id] = true
+		}
+	}
+
+	if arquivo != "" {
+		f, err := os.Open(arquivo)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			id := strings.TrimSpace(scanner.Text())
+			if id == "" || strings.HasPrefix(id, "#") {
+				continue
+			}
+			elegiveis[id] = true
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return elegiveis, nil
+}
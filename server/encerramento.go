@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Motivos de saída do processo sem resultado final, além dos de
+// encerramento da votação (MotivoTimeout e MotivoAdmin, em prazo.go).
+const (
+	MotivoSinal         = "signal"
+	MotivoCanalFechado  = "channel-closed"
+	MotivoConsumoFechou = "consumer-closed"
+	// Saída pelo "dump-state" com "stop", para outro servidor assumir.
+	MotivoTransferencia = "handoff"
+)
+
+// Motivo do encerramento em andamento; vazio enquanto a votação corre.
+var motivoEncerramento atomic.Value
+
+// Marca o início do encerramento, exposto no /metrics a partir daí.
+func marcarEncerramento(motivo string) {
+	motivoEncerramento.CompareAndSwap(nil, motivo)
+}
+
+// Métrica votacao_encerramento com o motivo como label, durante o
+// encerramento (carência, entrega do final e saída).
+func escreverEncerramento(w io.Writer) {
+	motivo, ok := motivoEncerramento.Load().(string)
+	if !ok {
+		return
+	}
+	fmt.Fprintln(w, "# HELP votacao_encerramento Votação em encerramento, com o motivo (timeout, admin, signal, channel-closed, consumer-closed ou handoff).")
+	fmt.Fprintln(w, "# TYPE votacao_encerramento gauge")
+	fmt.Fprintf(w, "votacao_encerramento{motivo=%q} 1\n", motivo)
+}
+
+// Avisa os clientes de que o servidor vai sair ("shutdown" e "offline") e
+// marca o desligamento, para que o fechamento da conexão não seja tomado
+// por uma queda do canal.
+func avisarDesligamento(ch Transport, motivo string) {
+	if err := enviarShutdown(ch, motivo); err != nil {
+		log.Printf("Erro ao anunciar o desligamento: %v\n", err)
+	}
+	if err := enviarOffline(ch); err != nil {
+		log.Printf("Erro ao anunciar o servidor fora do ar: %v\n", err)
+	}
+
+	// Pequena pausa para garantir que a mensagem saiu
+	time.Sleep(500 * time.Millisecond)
+	desligando.Store(true)
+}
+
+// Rotina comum de saída: registra o motivo e o código, grava o log de
+// votos, exporta os spans e remove o socket HTTP.
+func sair(motivo string, codigo int) {
+	marcarEncerramento(motivo)
+	log.Printf("Servidor encerrado (motivo: %s, código %d).\n", motivo, codigo)
+	sincronizarLogVotos()
+	finalizarTracing()
+	removerSocketHTTP()
+	os.Exit(codigo)
+}
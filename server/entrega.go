@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Política de novas tentativas na entrega do resultado final (broadcast,
+// LIVE_RESULTS_FILE e RESULT_WEBHOOK), configurável por FINAL_RETRIES,
+// FINAL_RETRY_INITIAL e FINAL_RETRY_MAX.
+type PoliticaEntrega struct {
+	// Total de tentativas por destino, incluindo a primeira.
+	tentativas int
+	backoff    Backoff
+}
+
+// Lê a política do ambiente; valores ausentes ficam no padrão (3
+// tentativas, espera de 1s dobrando até 10s) e um inválido encerra o
+// servidor.
+func lerPoliticaEntrega() PoliticaEntrega {
+	p := PoliticaEntrega{
+		tentativas: 3,
+		backoff:    Backoff{inicial: time.Second, maximo: 10 * time.Second, fator: 2},
+	}
+	if v := os.Getenv("FINAL_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("FINAL_RETRIES inválido: %q", v)
+		}
+		p.tentativas = n
+	}
+	if v := os.Getenv("FINAL_RETRY_INITIAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("FINAL_RETRY_INITIAL inválido: %q", v)
+		}
+		p.backoff.inicial = d
+	}
+	if v := os.Getenv("FINAL_RETRY_MAX"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("FINAL_RETRY_MAX inválido: %q", v)
+		}
+		p.backoff.maximo = d
+	}
+	if p.backoff.maximo < p.backoff.inicial {
+		p.backoff.maximo = p.backoff.inicial
+	}
+	return p
+}
+
+// Executa a entrega até dar certo ou esgotar as tentativas, registrando
+// cada falha com a espera até a próxima. Devolve o último erro.
+func (p PoliticaEntrega) entregar(destino string, f func() error) error {
+	var err error
+	for tentativa := 1; tentativa <= p.tentativas; tentativa++ {
+		if err = f(); err == nil {
+			if tentativa > 1 {
+				log.Printf("Entrega do final (%s): ok na tentativa %d\n", destino, tentativa)
+			}
+			return nil
+		}
+		if tentativa == p.tentativas {
+			log.Printf("Entrega do final (%s): tentativa %d/%d falhou: %v; sem novas tentativas\n", destino, tentativa, p.tentativas, err)
+			break
+		}
+		espera := p.backoff.espera(tentativa)
+		log.Printf("Entrega do final (%s): tentativa %d/%d falhou: %v; nova tentativa em %v\n", destino, tentativa, p.tentativas, err, espera)
+		time.Sleep(espera)
+	}
+	return err
+}
+
+// Escreve o resultado final (contagem e metadados, o mesmo corpo do
+// broadcast "final") como uma única linha JSON, para scripts como
+// "server -emit-result-stdout | jq .resultado". Os logs vão para stderr.
+func imprimirFinal(w io.Writer, final BroadcastMsg) error {
+	return json.NewEncoder(w).Encode(final)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+//
+// Transferência do estado entre servidores (comando "dump-state" e
+// -restore-state).
+//
+// Para trocar a versão do servidor sem perder uma votação em andamento,
+// o antigo grava o estado completo num arquivo e o novo sobe com ele:
+// votos por usuário, contagem, horários, seqs, nonces, rejeições e o
+// prazo. Com "stop": true, o antigo sai logo depois de gravar, ainda com
+// o stateMu travado: nenhum voto é contado depois do arquivo, e os que
+// estavam nos workers voltam para a fila sem ack ao fechar a conexão,
+// para o novo servidor contar. Um voto já contado cujo ack não saiu
+// chega de novo ao novo servidor, que o reconhece pelo nonce ou, sem
+// nonce, por ser uma reentrega (Redelivered) igual ao voto gravado do
+// usuário: só confirma de novo, sem ALREADY_VOTED nem rejeição contada.
+// Antes de sair, o antigo avisa os clientes com "shutdown" e "offline".
+// O arquivo fica no ADMIN_FILES_DIR (ver Controle.caminhoArquivo).
+//
+// O fim do prazo é absoluto: o tempo parado entre os dois servidores
+// conta contra a votação, e um prazo vencido nesse meio encerra a
+// votação assim que o novo sobe. Pausada, a votação volta pausada com o
+// mesmo tempo restante.
+//
+
+// Versão do formato do arquivo de estado.
+const versaoEstado = 1
+
+// Conteúdo do arquivo de estado.
+type EstadoServidor struct {
+	Versao  int    `json:"versao"`
+	Gravado string `json:"gravado"`
+
+	// Prazo: início, fim (enquanto corre ou antes da abertura) e o
+	// restante, em milissegundos, quando pausada.
+	Inicio     string `json:"inicio"`
+	Fim        string `json:"fim,omitempty"`
+	Pausada    bool   `json:"pausada,omitempty"`
+	RestanteMs int64  `json:"restanteMs,omitempty"`
+	// Votação já encerrada, com o motivo.
+	Encerrada bool   `json:"encerrada,omitempty"`
+	Motivo    string `json:"motivo,omitempty"`
+
+	Contagem     map[string]int    `json:"contagem"`
+	PrimeiroVoto map[string]string `json:"primeiroVoto,omitempty"`
+	UltimoVoto   map[string]string `json:"ultimoVoto,omitempty"`
+	Rejeicoes    map[string]int    `json:"rejeicoes,omitempty"`
+
+	// Estado por usuário, como nas fatias.
+	Votos        map[string]string `json:"votos"`
+	VotoEm       map[string]string `json:"votoEm,omitempty"`
+	Seqs         map[string]int64  `json:"seqs,omitempty"`
+	Nonces       map[string]string `json:"nonces,omitempty"`
+	Procuradores map[string]string `json:"procuradores,omitempty"`
+}
+
+// Copia o estado da apuração e do prazo. Chamado com o stateMu em modo
+// escrita.
+func (a *Apuracao) estadoTravado() EstadoServidor {
+	primeiro, ultimo := a.horariosVotos()
+	e := EstadoServidor{
+		Versao:       versaoEstado,
+		Gravado:      time.Now().Format(time.RFC3339Nano),
+		Contagem:     a.contagemAtual(),
+		PrimeiroVoto: formataTempos(primeiro),
+		UltimoVoto:   formataTempos(ultimo),
+		Rejeicoes:    a.rejeicoes.copia(),
+		Votos:        map[string]string{},
+		VotoEm:       map[string]string{},
+		Seqs:         map[string]int64{},
+		Nonces:       map[string]string{},
+		Procuradores: map[string]string{},
+	}
+	for i := range a.fatias {
+		f := &a.fatias[i]
+		for k, v := range f.votos {
+			e.Votos[k] = v
+		}
+		for k, v := range f.votoEm {
+			e.VotoEm[k] = v.Format(time.RFC3339Nano)
+		}
+		for k, v := range f.seqs {
+			e.Seqs[k] = v
+		}
+		for k, v := range f.nonces {
+			e.Nonces[k] = v
+		}
+		for k, v := range f.procuradores {
+			e.Procuradores[k] = v
+		}
+	}
+
+	inicio, fim, restante, pausado := a.prazo.Situacao()
+	e.Inicio = inicio.Format(time.RFC3339Nano)
+	if pausado {
+		e.Pausada, e.RestanteMs = true, restante.Milliseconds()
+	} else {
+		e.Fim = fim.Format(time.RFC3339Nano)
+	}
+	select {
+	case <-a.prazo.Expirou():
+		e.Encerrada = true
+		_, e.Motivo = a.prazo.Encerramento()
+	default:
+		e.Encerrada = a.encerrada.Load()
+	}
+	return e
+}
+
+// Carrega o estado nas estruturas vazias de uma apuração recém-criada,
+// antes de os workers começarem.
+func (a *Apuracao) restaurar(e EstadoServidor) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	primeiro, err := lerTempos(e.PrimeiroVoto)
+	if err != nil {
+		return err
+	}
+	ultimo, err := lerTempos(e.UltimoVoto)
+	if err != nil {
+		return err
+	}
+	for op, n := range e.Contagem {
+		st := a.estat(op)
+		st.votos.Store(int64(n))
+		if t, ok := primeiro[op]; ok {
+			st.primeiro.Store(t.UnixNano())
+		}
+		if t, ok := ultimo[op]; ok {
+			st.ultimo.Store(t.UnixNano())
+		}
+	}
+	for motivo, n := range e.Rejeicoes {
+		if c, ok := a.rejeicoes[motivo]; ok {
+			c.Store(int64(n))
+		}
+	}
+
+	for user, voto := range e.Votos {
+		f := a.fatia(user)
+		f.votos[user] = voto
+		if s, ok := e.VotoEm[user]; ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return fmt.Errorf("votoEm de %s: %w", user, err)
+			}
+			f.votoEm[user] = t
+		}
+		if seq, ok := e.Seqs[user]; ok {
+			f.seqs[user] = seq
+		}
+		if p, ok := e.Procuradores[user]; ok {
+			f.procuradores[user] = p
+		}
+	}
+	// O nonce fica na fatia do dono do voto.
+	for nonce, user := range e.Nonces {
+		a.fatia(user).nonces[nonce] = user
+	}
+	a.votantes.Store(int64(len(e.Votos)))
+	if e.Encerrada {
+		a.encerrar()
+	}
+	return nil
+}
+
+// Grava o estado de forma atômica (temporário + rename), como o arquivo
+// ao vivo.
+func gravarEstado(caminho string, e EstadoServidor) error {
+	body, _ := json.Marshal(e)
+
+	tmp, err := os.CreateTemp(filepath.Dir(caminho), ".estado-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(body, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), caminho)
+}
+
+// Lê o arquivo de -restore-state e o prazo que ele descreve.
+func lerEstado(caminho string) (EstadoServidor, error) {
+	var e EstadoServidor
+	dados, err := os.ReadFile(caminho)
+	if err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(dados, &e); err != nil {
+		return e, fmt.Errorf("%s: %w", caminho, err)
+	}
+	if e.Versao != versaoEstado {
+		return e, fmt.Errorf("%s: versão %d do estado não suportada (esperada %d)", caminho, e.Versao, versaoEstado)
+	}
+	return e, nil
+}
+
+// Prazo descrito no estado.
+func (e EstadoServidor) prazo() (*Prazo, error) {
+	inicio, err := time.Parse(time.RFC3339Nano, e.Inicio)
+	if err != nil {
+		return nil, fmt.Errorf("inicio: %w", err)
+	}
+	var fim time.Time
+	if !e.Pausada {
+		if fim, err = time.Parse(time.RFC3339Nano, e.Fim); err != nil {
+			return nil, fmt.Errorf("fim: %w", err)
+		}
+	}
+	p := restaurarPrazo(inicio, fim, e.Pausada, time.Duration(e.RestanteMs)*time.Millisecond)
+	if e.Encerrada {
+		motivo := e.Motivo
+		if motivo == "" {
+			motivo = MotivoTimeout
+		}
+		p.encerrarCom(motivo)
+	}
+	return p, nil
+}
+
+func lerTempos(tempos map[string]string) (map[string]time.Time, error) {
+	out := make(map[string]time.Time, len(tempos))
+	for op, s := range tempos {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("horário de %s: %w", op, err)
+		}
+		out[op] = t
+	}
+	return out, nil
+}
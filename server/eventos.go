@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//
+// Eventos da votação para um barramento externo (KAFKA_BROKERS e
+// KAFKA_TOPIC).
+//
+// Além dos broadcasts do RabbitMQ, cada voto aceito e o resultado final
+// viram um evento JSON no tópico, para pipelines de análise que não
+// falam AMQP. O suporte a Kafka só entra no binário compilado com
+// "-tags kafka" (kafka.go); sem a tag, definir KAFKA_BROKERS impede o
+// servidor de subir, em vez de descartar os eventos em silêncio.
+//
+// Esquema (campo "schema" = "votacao.evento/v1"):
+//   - tipo "voto": userId, opcoes, anteriores (revoto), procurador
+//     (voto por procuração) e timestamp; chave da mensagem = userId, então
+//     os votos de um usuário ficam na mesma partição, em ordem.
+//   - tipo "final": resultado, vencedor, motivo, inicio, fim, votantes e
+//     timestamp; chave = "final".
+// Campos novos podem aparecer na v1; mudanças incompatíveis trocam a versão.
+//
+
+// Versão do esquema dos eventos.
+const esquemaEventos = "votacao.evento/v1"
+
+// Evento publicado no barramento.
+type EventoVotacao struct {
+	Schema    string `json:"schema"`
+	Tipo      string `json:"tipo"`
+	Timestamp string `json:"timestamp"`
+
+	// Tipo "voto".
+	UserID     string   `json:"userId,omitempty"`
+	Opcoes     []string `json:"opcoes,omitempty"`
+	Anteriores []string `json:"anteriores,omitempty"`
+	Procurador string   `json:"procurador,omitempty"`
+
+	// Tipo "final".
+	Resultado map[string]int `json:"resultado,omitempty"`
+	Vencedor  string         `json:"vencedor,omitempty"`
+	Motivo    string         `json:"motivo,omitempty"`
+	Inicio    string         `json:"inicio,omitempty"`
+	Fim       string         `json:"fim,omitempty"`
+	Votantes  int            `json:"votantes,omitempty"`
+}
+
+// Destino dos eventos. Os votos são enfileirados sem bloquear o worker;
+// o final espera a entrega dos votos pendentes e a dele mesmo.
+type DestinoEventos interface {
+	voto(chave string, ev EventoVotacao)
+	final(ctx context.Context, ev EventoVotacao) error
+}
+
+// Destino global; nil sem KAFKA_BROKERS.
+var destinoEventos DestinoEventos
+
+// Abre o destino configurado por KAFKA_BROKERS (separados por vírgula) e
+// KAFKA_TOPIC; nil quando KAFKA_BROKERS está vazio.
+func abrirDestinoEventos(brokers, topico string) (DestinoEventos, error) {
+	var lista []string
+	for _, b := range strings.Split(brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			lista = append(lista, b)
+		}
+	}
+	if len(lista) == 0 {
+		return nil, nil
+	}
+	if topico == "" {
+		return nil, fmt.Errorf("KAFKA_TOPIC é obrigatório com KAFKA_BROKERS")
+	}
+	return abrirKafka(lista, topico)
+}
+
+// Publica o final no destino, com o prazo dos outros destinos do final.
+func publicarFinalEventos(final BroadcastMsg) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeoutFinal)
+	defer cancel()
+	return destinoEventos.final(ctx, eventoFinal(final))
+}
+
+func eventoVoto(user string, d Decisao, procurador string) EventoVotacao {
+	return EventoVotacao{
+		Schema:     esquemaEventos,
+		Tipo:       "voto",
+		Timestamp:  d.Quando.Format(time.RFC3339Nano),
+		UserID:     user,
+		Opcoes:     d.Escolhas,
+		Anteriores: d.Anteriores,
+		Procurador: procurador,
+	}
+}
+
+func eventoFinal(final BroadcastMsg) EventoVotacao {
+	return EventoVotacao{
+		Schema:    esquemaEventos,
+		Tipo:      "final",
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Resultado: final.Result,
+		Vencedor:  final.Vencedor,
+		Motivo:    final.Motivo,
+		Inicio:    final.Inicio,
+		Fim:       final.Fim,
+		Votantes:  final.Votantes,
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Cor de exibição aceita: "#rrggbb", que o cliente converte em cor ANSI.
+var formatoCor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Aplica OPTION_LABELS e OPTION_COLORS ("A:Apple 🍎,B:Banana") às opções
+// de uma lista fixa. Valem sobre o arquivo de opções onde os dois definem
+// a mesma chave, como o CAPS. Rótulos não podem conter vírgula; para
+// isso, use o arquivo.
+func aplicarExibicao(rotulos, cores string, validador Validador) error {
+	if rotulos == "" && cores == "" {
+		return nil
+	}
+	c, ok := validador.(*conjuntoOpcoes)
+	if !ok {
+		return fmt.Errorf("rótulos e cores exigem uma lista de opções, não um intervalo")
+	}
+	if c.rotulos == nil {
+		c.rotulos = map[string]string{}
+	}
+	if c.cores == nil {
+		c.cores = map[string]string{}
+	}
+	if err := lerExibicao(rotulos, "rótulo", c, c.rotulos, nil); err != nil {
+		return err
+	}
+	return lerExibicao(cores, "cor", c, c.cores, formatoCor)
+}
+
+// Lê pares "opção:valor" para o mapa; formato, quando não nil, valida o
+// valor.
+func lerExibicao(spec, nome string, c *conjuntoOpcoes, destino map[string]string, formato *regexp.Regexp) error {
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		op, valor, ok := strings.Cut(item, ":")
+		if !ok {
+			return fmt.Errorf("%s %q fora do formato opção:valor", nome, item)
+		}
+		op, valor = strings.TrimSpace(op), strings.TrimSpace(valor)
+		if !c.Valid(op) {
+			return fmt.Errorf("%s para opção desconhecida %q", nome, op)
+		}
+		if valor == "" || (formato != nil && !formato.MatchString(valor)) {
+			return fmt.Errorf("%s inválido para %q: %q", nome, op, valor)
+		}
+		destino[op] = valor
+	}
+	return nil
+}
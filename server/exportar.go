@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"strconv"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Responde com a contagem atual (ou final, se a votação já encerrou) em
+// JSON ou CSV, para operadores sem acesso ao disco do servidor.
+func (ct *Controle) exportar(d amqp.Delivery, c Comando) {
+	resultado := ct.apuracao.resultadoFinal()
+
+	final := false
+	select {
+	case <-ct.prazo.Expirou():
+		final = true
+	default:
+	}
+
+	resp := RespostaControle{Cmd: c.Cmd, Ok: true, Final: final}
+	switch c.Formato {
+	case "", "json":
+		resp.Resultado = resultado.Result
+		resp.Vencedor = resultado.Vencedor
+	case "csv":
+		resp.Corpo = contagemCSV(resultado.Result)
+	default:
+		responderControle(ct.ch, d, RespostaControle{Cmd: c.Cmd, Mensagem: "Formato desconhecido (use json ou csv)."})
+		return
+	}
+	responderControle(ct.ch, d, resp)
+}
+
+// Monta o CSV "opcao,votos" com as opções em ordem alfabética.
+func contagemCSV(contagem map[string]int) string {
+	opcoes := make([]string, 0, len(contagem))
+	for op := range contagem {
+		opcoes = append(opcoes, op)
+	}
+	sort.Strings(opcoes)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"opcao", "votos"})
+	for _, op := range opcoes {
+		w.Write([]string{op, strconv.Itoa(contagem[op])})
+	}
+	w.Flush()
+	return buf.String()
+}
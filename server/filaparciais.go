@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+//
+// Fila de parciais entre os workers e o envio.
+//
+// O envio de broadcasts é serializado pelo amqpMu e, sob carga extrema,
+// não acompanha a contagem. Os workers depositam a parcial numa fila
+// limitada e seguem para o próximo voto; com a fila cheia, a parcial é
+// descartada, já que a próxima traz uma contagem mais nova. O final não
+// passa por aqui e é sempre enviado.
+//
+
+// Parciais descartadas com a fila cheia, exposto em GET /metrics.
+var parciaisDescartadas atomic.Int64
+
+// FilaParciais publica as parciais numa goroutine própria.
+type FilaParciais struct {
+	ch       Transport
+	apuracao *Apuracao
+	fila     chan BroadcastMsg
+	// Houve descarte desde a última parcial enviada. No modo delta a
+	// próxima vai completa, já que os deltas descartados fariam falta.
+	lacuna atomic.Bool
+
+	// Protege o envio em andamento e a parada antes do final.
+	mu     sync.Mutex
+	parada bool
+}
+
+func novaFilaParciais(ch Transport, apuracao *Apuracao, tamanho int) *FilaParciais {
+	return &FilaParciais{
+		ch:       ch,
+		apuracao: apuracao,
+		fila:     make(chan BroadcastMsg, tamanho),
+	}
+}
+
+// Deposita a parcial sem bloquear o worker.
+func (f *FilaParciais) enfileirar(parcial BroadcastMsg) {
+	select {
+	case f.fila <- parcial:
+	default:
+		parciaisDescartadas.Add(1)
+		f.lacuna.Store(true)
+	}
+}
+
+// Envia as parciais na ordem em que foram contadas, até parar.
+func (f *FilaParciais) executar() {
+	for parcial := range f.fila {
+		f.mu.Lock()
+		if f.parada {
+			f.mu.Unlock()
+			return
+		}
+		if f.apuracao.deltas && f.lacuna.Swap(false) {
+			parcial = f.apuracao.parcialCompleta()
+		}
+		if err := enviarParcial(f.ch, parcial); err != nil {
+			log.Printf("Erro ao enviar parcial: %v\n", err)
+		}
+		f.mu.Unlock()
+	}
+}
+
+// Descarta as parciais restantes, esperando o envio em andamento, para
+// que nenhuma parcial saia depois do resultado final.
+func (f *FilaParciais) parar() {
+	f.mu.Lock()
+	f.parada = true
+	f.mu.Unlock()
+}
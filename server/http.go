@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+//
+// Listener HTTP de diagnóstico (HEALTH_ADDR).
+//
+
+// Configuração efetivamente carregada pelo servidor, exposta em
+// GET /config. Segredos (como o ADMIN_TOKEN) nunca aparecem aqui, apenas
+// se estão configurados.
+type ConfigEfetiva struct {
+	Opcoes              string `json:"opcoes"`
+	MaxSelecoes         int    `json:"maxSelecoes"`
+	Tally               string `json:"tally"`
+	Timeout             string `json:"timeout"`
+	IdleTimeout         string `json:"idleTimeout"`
+	Prazo               string `json:"prazo"`
+	VotingStart         string `json:"votingStart,omitempty"`
+	Pausado             bool   `json:"pausado"`
+	NumWorkers          int    `json:"numWorkers"`
+	Prefetch            int    `json:"prefetch"`
+	PrefetchAuto        string `json:"prefetchAuto,omitempty"`
+	ConfirmDelay        string `json:"confirmDelay"`
+	PublishTimeout      string `json:"publishTimeout"`
+	FinalPublishTimeout string `json:"finalPublishTimeout"`
+	DrainGrace          string `json:"drainGrace"`
+	HeartbeatInterval   string `json:"heartbeatInterval"`
+	CompressThreshold   int    `json:"compressThreshold"`
+	Codec               string `json:"codec"`
+	PartialDeltas       bool   `json:"partialDeltas"`
+	PartialFullEvery    int    `json:"partialFullEvery,omitempty"`
+	PartialQueueSize    int    `json:"partialQueueSize"`
+	VoteMaxPriority     int    `json:"voteMaxPriority"`
+	MaxMsgBytes         int    `json:"maxMsgBytes"`
+	BroadcastMandatory  bool   `json:"broadcastMandatory"`
+	HidePartials        bool   `json:"hidePartials"`
+	OrderedPerUser      bool   `json:"orderedPerUser"`
+	AuditStream         bool   `json:"auditStream"`
+	AuditQueue          string `json:"auditQueue,omitempty"`
+	Durable             bool   `json:"durable"`
+	BenchMode           bool   `json:"benchMode,omitempty"`
+	VoteLog             string `json:"voteLog,omitempty"`
+	VoteLogFsync        string `json:"voteLogFsync,omitempty"`
+	PersistenceMode     string `json:"persistenceMode"`
+	LiveResultsFile     string `json:"liveResultsFile,omitempty"`
+	KafkaTopic          string `json:"kafkaTopic,omitempty"`
+	Elegiveis           int    `json:"elegiveis,omitempty"`
+	Delegacoes          int    `json:"delegacoes,omitempty"`
+	Caps                string `json:"caps,omitempty"`
+	MaxVoters           int64  `json:"maxVoters,omitempty"`
+	PowDifficulty       int    `json:"powDifficulty,omitempty"`
+	OptionsFile         string `json:"optionsFile,omitempty"`
+	OptionLabels        string `json:"optionLabels,omitempty"`
+	OptionColors        string `json:"optionColors,omitempty"`
+	ConfigFile          string `json:"configFile,omitempty"`
+	Desempate           string `json:"desempate"`
+	ExchangePrefix      string `json:"exchangePrefix"`
+	Recibos             bool   `json:"recibos"`
+	Revoto              bool   `json:"revoto"`
+	RevoteCooldown      string `json:"revoteCooldown"`
+	RequireVoteSeq      bool   `json:"requireVoteSeq"`
+	ConnectionName      string `json:"connectionName"`
+	Broker              string `json:"broker"`
+	ControleHabilitado  bool   `json:"controleHabilitado"`
+
+	// Backoff da conexão com o broker (RECONNECT_*).
+	ReconnectInitial string  `json:"reconnectInitial"`
+	ReconnectMax     string  `json:"reconnectMax"`
+	ReconnectFactor  float64 `json:"reconnectFactor"`
+
+	// Novas tentativas na entrega do final (FINAL_RETRY*).
+	FinalRetries      int    `json:"finalRetries"`
+	FinalRetryInitial string `json:"finalRetryInitial"`
+	FinalRetryMax     string `json:"finalRetryMax"`
+
+	// Ligações extras da fila de votos (VOTE_BINDINGS).
+	VoteBindings []string `json:"voteBindings,omitempty"`
+
+	// Votos anônimos deduplicados por ballotHash (ALLOW_BALLOT_HASH).
+	AllowBallotHash bool `json:"allowBallotHash"`
+	// UserID pelo certificado do cliente (VOTER_IDENTITY=cert).
+	IdentityFromCert bool `json:"identityFromCert"`
+
+	// Origens aceitas pelo CORS das rotas HTTP (CORS_ORIGINS).
+	CorsOrigins []string `json:"corsOrigins,omitempty"`
+
+	// Idiomas com rota própria no broadcast (BROADCAST_LANGS).
+	BroadcastLangs []string `json:"broadcastLangs,omitempty"`
+
+	// Diretório dos arquivos do "audit" e do "dump-state" (ADMIN_FILES_DIR).
+	AdminFilesDir string `json:"adminFilesDir,omitempty"`
+}
+
+// Inicia o listener HTTP em segundo plano com as rotas de diagnóstico.
+func servirHTTP(addr string, cfg ConfigEfetiva, prazo *Prazo, apuracao *Apuracao, ch Transport, token string) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, r *http.Request) {
+		// O prazo muda com pausas e reinícios; é lido a cada requisição.
+		c := cfg
+		fim, pausado := prazo.Fim()
+		c.Prazo = fim.Format(time.RFC3339)
+		c.Pausado = pausado
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(c)
+	})
+
+	// Contadores no formato de texto do Prometheus.
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# HELP votacao_parciais_descartadas_total Parciais descartadas com a fila de envio cheia.")
+		fmt.Fprintln(w, "# TYPE votacao_parciais_descartadas_total counter")
+		fmt.Fprintf(w, "votacao_parciais_descartadas_total %d\n", parciaisDescartadas.Load())
+		degradada := 0
+		if persistenciaDegradada.Load() {
+			degradada = 1
+		}
+		fmt.Fprintln(w, "# HELP votacao_persistencia_degradada 1 se o log de votos não abriu ou teve falha de escrita.")
+		fmt.Fprintln(w, "# TYPE votacao_persistencia_degradada gauge")
+		fmt.Fprintf(w, "votacao_persistencia_degradada %d\n", degradada)
+		fmt.Fprintln(w, "# HELP votacao_persistencia_falhas_total Falhas de abertura, escrita ou fsync do log de votos.")
+		fmt.Fprintln(w, "# TYPE votacao_persistencia_falhas_total counter")
+		fmt.Fprintf(w, "votacao_persistencia_falhas_total %d\n", falhasPersistencia.Load())
+		escreverEncerramento(w)
+		esperaAmqpMu.escrever(w, "votacao_broadcast_espera_lock_segundos", "Espera pelo Lock do canal AMQP antes de publicar um broadcast.")
+		duracaoPublish.escrever(w, "votacao_broadcast_publish_segundos", "Duração do publish de um broadcast, depois de obter o Lock.")
+	})
+
+	// Parciais e final em tempo real para dashboards web.
+	mux.HandleFunc("GET /stream", servirStream(apuracao))
+
+	// Snapshot manual, como o comando "snapshot"; exige o ADMIN_TOKEN no
+	// cabeçalho Authorization: Bearer.
+	if token != "" {
+		mux.HandleFunc("POST /snapshot", func(w http.ResponseWriter, r *http.Request) {
+			recebido := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(recebido), []byte(token)) != 1 {
+				http.Error(w, "token inválido", http.StatusUnauthorized)
+				return
+			}
+			err := enviarInstantaneo(ch, apuracao)
+			switch {
+			case errors.Is(err, errParciaisOcultas):
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte("ok\n"))
+		})
+	}
+
+	ln, err := escutarHTTP(addr)
+	if err != nil {
+		log.Printf("Erro no listener HTTP: %v\n", err)
+		return
+	}
+	go func() {
+		log.Printf("Listener HTTP em %s\n", addr)
+		if err := http.Serve(ln, comCORS(cfg.CorsOrigins, mux)); err != nil {
+			log.Printf("Erro no listener HTTP: %v\n", err)
+		}
+	}()
+}
+
+// Abre o listener em TCP (":8080") ou num socket Unix
+// ("unix:/run/votacao.sock"). Um socket deixado por uma execução anterior
+// é removido; outro tipo de arquivo no caminho é erro.
+func escutarHTTP(addr string) (net.Listener, error) {
+	caminho, unix := strings.CutPrefix(addr, "unix:")
+	if !unix {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(caminho); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s existe e não é um socket", caminho)
+		}
+		os.Remove(caminho)
+	}
+	return net.Listen("unix", caminho)
+}
+
+// Remove o socket Unix do HEALTH_ADDR ao encerrar; o os.Exit não roda o
+// Close do listener.
+func removerSocketHTTP() {
+	if caminho, ok := strings.CutPrefix(os.Getenv("HEALTH_ADDR"), "unix:"); ok {
+		os.Remove(caminho)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+//
+// Identidade do eleitor pelo certificado TLS (VOTER_IDENTITY=cert).
+//
+// O servidor não vê a conexão TLS do cliente, só o broker. Com o plugin
+// rabbitmq_auth_mechanism_ssl, o broker autentica o cliente pelo CN ou
+// SAN do certificado (ssl_cert_login_from) e, para qualquer mensagem
+// publicada com a propriedade user_id, confere que ela é exatamente o
+// usuário autenticado da conexão; senão, fecha o canal. Assim o user_id
+// que chega aqui é a identidade do certificado, e o UserID do corpo é
+// ignorado.
+//
+// A garantia depende da configuração do broker: nenhum usuário com a tag
+// impersonator pode ter permissão de escrita na exchange de votos, e os
+// eleitores devem entrar só por certificado (EXTERNAL), sem senha.
+//
+
+// Usa o user_id validado pelo broker como UserID (VOTER_IDENTITY=cert).
+var identidadePeloBroker bool
+
+// Troca o UserID do corpo pelo user_id da mensagem. Sem user_id, o voto é
+// recusado com IDENTITY_REQUIRED.
+func identificarPeloBroker(msg amqp.Delivery, v Voto) (Voto, string) {
+	if msg.UserId == "" {
+		return v, CodSemIdentidade
+	}
+	if v.UserID != "" && v.UserID != msg.UserId {
+		log.Printf("UserID %q do corpo ignorado; identidade do certificado: %s\n", v.UserID, msg.UserId)
+	}
+	v.UserID = msg.UserId
+	return v, ""
+}
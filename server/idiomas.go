@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//
+// Broadcast por idioma (BROADCAST_LANGS).
+//
+// Sem a variável, a exchange de broadcast é fanout e cada confirmação ou
+// erro sai uma vez, no idioma do voto. Com ela, a exchange é topic: o que
+// não depende de idioma (parciais, final, heartbeat, avisos do servidor)
+// sai uma vez na rota "broadcast", e confirmações e erros saem traduzidos
+// em "broadcast.<idioma>", uma cópia por idioma da lista. Cada cliente
+// liga a fila a "broadcast" e à rota do seu idioma e só recebe um texto
+// por mensagem.
+//
+// Um voto num idioma fora da lista também recebe uma cópia na rota do seu
+// idioma, para que o próprio eleitor veja a confirmação.
+//
+
+// Rota das mensagens sem idioma na exchange topic.
+const rotaBroadcast = "broadcast"
+
+// Idiomas com rota própria no broadcast; vazio mantém o fanout.
+var idiomasBroadcast []string
+
+// Lê a lista de idiomas separada por vírgula (ex.: "pt-BR,en,es"). Cada
+// um precisa ter textos no catálogo, direto ou pelo idioma base.
+func lerIdiomasBroadcast(spec string) ([]string, error) {
+	var idiomas []string
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || slices.Contains(idiomas, item) {
+			continue
+		}
+		if !idiomaValido(item) {
+			return nil, fmt.Errorf("idioma %q inválido (use letras, dígitos e hífen, ex.: pt-BR)", item)
+		}
+		base, _, _ := strings.Cut(item, "-")
+		_, ok := catalogo[item]
+		if _, okBase := catalogo[base]; !ok && !okBase {
+			return nil, fmt.Errorf("idioma %q sem mensagens no catálogo", item)
+		}
+		idiomas = append(idiomas, item)
+	}
+	return idiomas, nil
+}
+
+// Idioma que pode virar uma palavra da routing key: sem ".", "*" nem "#",
+// que têm significado nos bindings de uma exchange topic.
+func idiomaValido(lang string) bool {
+	if lang == "" || len(lang) > 35 {
+		return false
+	}
+	for _, r := range lang {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Tipo da exchange de broadcast.
+func tipoBroadcast() string {
+	if len(idiomasBroadcast) > 0 {
+		return "topic"
+	}
+	return "fanout"
+}
+
+// Rota das mensagens sem idioma. No fanout a rota é ignorada e continua
+// vazia, como antes da lista de idiomas.
+func rotaNeutra() string {
+	if len(idiomasBroadcast) > 0 {
+		return rotaBroadcast
+	}
+	return ""
+}
+
+func rotaIdioma(lang string) string {
+	return rotaBroadcast + "." + lang
+}
+
+// Idiomas em que sai uma mensagem para o usuário: os da lista e o do
+// voto, se estiver fora dela.
+func idiomasDestino(lang string) []string {
+	if slices.Contains(idiomasBroadcast, lang) || !idiomaValido(lang) {
+		return idiomasBroadcast
+	}
+	return append(slices.Clip(idiomasBroadcast), lang)
+}
+
+// Publica a mensagem para um usuário: uma vez, no idioma do voto, sem
+// BROADCAST_LANGS, ou uma cópia traduzida por idioma com ela.
+func publicarPorIdioma(ch Transport, lang string, montar func(idioma string) BroadcastMsg) error {
+	if len(idiomasBroadcast) == 0 {
+		return publicarBroadcast(ch, "", montar(lang), publishTimeout)
+	}
+	for _, idioma := range idiomasDestino(lang) {
+		if err := publicarBroadcast(ch, rotaIdioma(idioma), montar(idioma), publishTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
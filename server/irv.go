@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"votacao-rabbitmq/client/votacao"
+)
+
+//
+// Apuração por ranking com segundo turno instantâneo (TALLY=irv).
+//
+// Cada voto traz "ranking": as opções em ordem de preferência. Durante a
+// votação, a contagem (parciais e "resultado") é a das primeiras
+// preferências; o ranking inteiro fica guardado no estado do usuário.
+// No encerramento, cada rodada conta cada cédula para a opção mais bem
+// colocada que ainda está na disputa. Se uma opção tem mais da metade
+// das cédulas ainda ativas, vence; senão, a menos votada sai e as cédulas
+// dela passam para a próxima preferência. Empatadas em último saem
+// juntas; quando todas as restantes empatam, o TIE_BREAK decide, como na
+// maioria simples. Cédulas sem nenhuma opção restante ficam esgotadas.
+//
+
+// Uma rodada da apuração, publicada no "final". As regras das rodadas
+// ficam na biblioteca votacao, compartilhadas com o replay.
+type RodadaIRV = votacao.RodadaIRV
+
+// Rankings guardados no estado por usuário. Chamado com o stateMu em modo
+// escrita.
+func (a *Apuracao) rankings() [][]string {
+	var cedulas [][]string
+	for i := range a.fatias {
+		for _, r := range a.fatias[i].votos {
+			cedulas = append(cedulas, strings.Split(r, ","))
+		}
+	}
+	return cedulas
+}
+
+// Apura o vencedor e as rodadas. opcoes são as opções na disputa desde o
+// início (as do Validador e as que aparecem nas cédulas).
+func apurarIRV(cedulas [][]string, opcoes []string, ultimoVoto map[string]time.Time, politica string, semente int64) (string, []RodadaIRV) {
+	rodadas := votacao.RodadasIRV(cedulas, opcoes)
+	if len(rodadas) == 0 {
+		return "", nil
+	}
+	return definirVencedor(rodadas[len(rodadas)-1].Contagem, ultimoVoto, politica, semente), rodadas
+}
@@ -0,0 +1,60 @@
+//go:build kafka
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Destino Kafka: um writer assíncrono para os votos, que não bloqueia os
+// workers, e um síncrono para o final, publicado só depois de os votos
+// pendentes serem entregues.
+type DestinoKafka struct {
+	votos  *kafka.Writer
+	finais *kafka.Writer
+}
+
+func abrirKafka(brokers []string, topico string) (DestinoEventos, error) {
+	d := &DestinoKafka{
+		votos: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topico,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 50 * time.Millisecond,
+			Async:        true,
+			Completion: func(msgs []kafka.Message, err error) {
+				if err != nil {
+					log.Printf("Erro ao publicar %d voto(s) no Kafka: %v\n", len(msgs), err)
+				}
+			},
+		},
+		finais: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topico,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchSize:    1,
+		},
+	}
+	return d, nil
+}
+
+func (d *DestinoKafka) voto(chave string, ev EventoVotacao) {
+	body, _ := json.Marshal(ev)
+	// Com Async, o erro só chega no Completion.
+	d.votos.WriteMessages(context.Background(), kafka.Message{Key: []byte(chave), Value: body})
+}
+
+// O Close do writer de votos espera os lotes pendentes; fechado, ele não
+// aceita mais votos, então o final só é chamado depois do encerramento.
+func (d *DestinoKafka) final(ctx context.Context, ev EventoVotacao) error {
+	d.votos.Close()
+	body, _ := json.Marshal(ev)
+	return d.finais.WriteMessages(ctx, kafka.Message{Key: []byte("final"), Value: body})
+}
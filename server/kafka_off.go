@@ -0,0 +1,10 @@
+//go:build !kafka
+
+package main
+
+import "fmt"
+
+// Sem a tag kafka, o binário não traz o cliente Kafka.
+func abrirKafka(brokers []string, topico string) (DestinoEventos, error) {
+	return nil, fmt.Errorf("servidor compilado sem suporte a Kafka; recompile com -tags kafka")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lê os limites de vagas por opção no formato "A:50,B:100". Opções sem
+// limite aceitam votos sem restrição. Retorna nil com a spec vazia.
+func carregarLimites(spec string, validador Validador) (map[string]int, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	limites := map[string]int{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		op, valor, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("limite %q fora do formato opção:vagas", item)
+		}
+		op = strings.TrimSpace(op)
+		if !validador.Valid(op) {
+			return nil, fmt.Errorf("limite para opção desconhecida %q", op)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(valor))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("vagas inválidas para %q: %q", op, valor)
+		}
+		limites[op] = n
+	}
+	return limites, nil
+}
+
+// Conta o voto nas opções que entram, reservando as vagas das que têm
+// limite com compare-and-swap: a verificação e a contagem são uma só
+// operação, sem Lock global. Se alguma estiver lotada, desfaz as já
+// reservadas e retorna false.
+func (a *Apuracao) reservar(novas []string) bool {
+	for i, op := range novas {
+		if !a.ocupar(op) {
+			for _, feita := range novas[:i] {
+				a.estat(feita).votos.Add(-1)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Soma um voto à opção, se ainda houver vaga.
+func (a *Apuracao) ocupar(op string) bool {
+	st := a.estat(op)
+	limite, ok := a.limites[op]
+	if !ok {
+		st.votos.Add(1)
+		return true
+	}
+	for {
+		n := st.votos.Load()
+		if n >= int64(limite) {
+			return false
+		}
+		if st.votos.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// Opções que já atingiram o limite, em ordem alfabética.
+func (a *Apuracao) fechadas() []string {
+	var lotadas []string
+	for op, limite := range a.limites {
+		if a.estat(op).votos.Load() >= int64(limite) {
+			lotadas = append(lotadas, op)
+		}
+	}
+	sort.Strings(lotadas)
+	return lotadas
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// Log de votos (VOTE_LOG): uma linha JSON por voto aceito.
+//
+// O log traz tudo que a ferramenta replay/ precisa para recontar a
+// votação sem o servidor: usuário, opções, peso e horário. O horário é o
+// mesmo registrado sob o Lock da fatia do usuário, então ordenar por ele
+// reproduz a ordem em que os votos de cada usuário foram contados, mesmo
+// que as linhas saiam fora de ordem.
+//
+// Cada linha vai direto ao kernel (sem buffer no processo), então um
+// crash do servidor não perde votos já registrados. O fsync, que protege
+// contra queda do sistema ou de energia, segue VOTE_LOG_FSYNC:
+//
+//   - vote (padrão): fsync a cada linha, antes da confirmação ao usuário.
+//     Uma queda perde no máximo o voto que estava sendo gravado, e todo
+//     voto confirmado está no disco.
+//   - batch:N: fsync a cada N linhas. Uma queda perde até N-1 votos já
+//     confirmados.
+//   - interval:D: fsync a cada D (ex.: interval:500ms), se houve escrita.
+//     Uma queda perde os votos dos últimos D.
+//   - off: sem fsync; o sistema grava quando quiser (em geral até ~30s
+//     no Linux).
+//
+// Nos modos batch e interval o encerramento normal faz um fsync final.
+//
+// PERSISTENCE_MODE decide o que fazer se o log não abrir: strict (padrão)
+// não sobe o servidor; lenient avisa e segue só em memória. Nos dois
+// modos, uma falha de escrita no meio da votação não para a contagem;
+// ela marca a persistência como degradada no log e no /metrics.
+//
+
+// Linha do log. Reset marca o comando "reset", que descarta os votos
+// anteriores na recontagem.
+type RegistroVoto struct {
+	UserID    string   `json:"userId,omitempty"`
+	Opcoes    []string `json:"opcoes,omitempty"`
+	Peso      int      `json:"peso,omitempty"`
+	Nonce     string   `json:"nonce,omitempty"`
+	Timestamp string   `json:"timestamp"`
+	Reset     bool     `json:"reset,omitempty"`
+}
+
+// Política de fsync do log (VOTE_LOG_FSYNC).
+type PoliticaFsync struct {
+	// modo é vote, batch, interval ou off.
+	modo      string
+	lote      int
+	intervalo time.Duration
+}
+
+func (p PoliticaFsync) String() string {
+	switch p.modo {
+	case "batch":
+		return fmt.Sprintf("batch:%d", p.lote)
+	case "interval":
+		return "interval:" + p.intervalo.String()
+	}
+	return p.modo
+}
+
+// Lê a política no formato vote, batch:N, interval:D ou off; vazio é vote.
+func lerPoliticaFsync(spec string) (PoliticaFsync, error) {
+	modo, valor, _ := strings.Cut(spec, ":")
+	switch modo {
+	case "", "vote":
+		return PoliticaFsync{modo: "vote"}, nil
+	case "off":
+		return PoliticaFsync{modo: "off"}, nil
+	case "batch":
+		n, err := strconv.Atoi(valor)
+		if err != nil || n < 1 {
+			return PoliticaFsync{}, fmt.Errorf("lote inválido em %q (use batch:N, N >= 1)", spec)
+		}
+		return PoliticaFsync{modo: "batch", lote: n}, nil
+	case "interval":
+		d, err := time.ParseDuration(valor)
+		if err != nil || d <= 0 {
+			return PoliticaFsync{}, fmt.Errorf("intervalo inválido em %q (use interval:D, ex.: interval:1s)", spec)
+		}
+		return PoliticaFsync{modo: "interval", intervalo: d}, nil
+	}
+	return PoliticaFsync{}, fmt.Errorf("política %q desconhecida (vote, batch:N, interval:D ou off)", spec)
+}
+
+// Arquivo do log, com escrita serializada entre os workers.
+type LogVotos struct {
+	mu       sync.Mutex
+	f        *os.File
+	politica PoliticaFsync
+	// Linhas escritas desde o último fsync.
+	pendentes int
+}
+
+// Log global; nil quando VOTE_LOG não está definido ou não abriu no
+// modo lenient.
+var logVotos *LogVotos
+
+// Persistência degradada: o log não abriu (lenient) ou uma escrita ou
+// fsync falhou. Exposto no /metrics com o total de falhas.
+var (
+	persistenciaDegradada atomic.Bool
+	falhasPersistencia    atomic.Int64
+)
+
+// Registra uma falha do log de votos; o aviso de degradação sai uma vez.
+func falhaPersistencia(err error) {
+	falhasPersistencia.Add(1)
+	if persistenciaDegradada.CompareAndSwap(false, true) {
+		log.Printf("AVISO: persistência degradada, votos podem faltar no log: %v\n", err)
+	}
+}
+
+// Abre (ou cria) o arquivo em modo append. No modo interval, inicia o
+// fsync periódico.
+func abrirLogVotos(caminho string, politica PoliticaFsync) (*LogVotos, error) {
+	f, err := os.OpenFile(caminho, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &LogVotos{f: f, politica: politica}
+	if politica.modo == "interval" {
+		go func() {
+			for range time.Tick(politica.intervalo) {
+				if err := l.sincronizar(); err != nil {
+					log.Printf("Erro no fsync do log de votos: %v\n", err)
+					falhaPersistencia(err)
+				}
+			}
+		}()
+	}
+	return l, nil
+}
+
+// Registra um voto aceito. Cada voto pesa 1.
+func (l *LogVotos) registrarVoto(user string, escolhas []string, nonce string, quando time.Time) error {
+	return l.escrever(RegistroVoto{
+		UserID:    user,
+		Opcoes:    escolhas,
+		Peso:      1,
+		Nonce:     nonce,
+		Timestamp: quando.Format(time.RFC3339Nano),
+	})
+}
+
+// Registra o reset da votação.
+func (l *LogVotos) registrarReset(quando time.Time) error {
+	return l.escrever(RegistroVoto{Reset: true, Timestamp: quando.Format(time.RFC3339Nano)})
+}
+
+func (l *LogVotos) escrever(r RegistroVoto) error {
+	err := l.gravar(r)
+	if err != nil {
+		falhaPersistencia(err)
+	}
+	return err
+}
+
+func (l *LogVotos) gravar(r RegistroVoto) error {
+	linha, _ := json.Marshal(r)
+	linha = append(linha, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Write(linha); err != nil {
+		return err
+	}
+	l.pendentes++
+	switch l.politica.modo {
+	case "vote":
+		return l.fsync()
+	case "batch":
+		if l.pendentes >= l.politica.lote {
+			return l.fsync()
+		}
+	}
+	return nil
+}
+
+// Grava no disco as linhas pendentes, se houver.
+func (l *LogVotos) sincronizar() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pendentes == 0 {
+		return nil
+	}
+	return l.fsync()
+}
+
+// Chamado com o mu.
+func (l *LogVotos) fsync() error {
+	l.pendentes = 0
+	return l.f.Sync()
+}
+
+// fsync final antes do os.Exit; sem log, não faz nada.
+func sincronizarLogVotos() {
+	if logVotos == nil {
+		return
+	}
+	if err := logVotos.sincronizar(); err != nil {
+		log.Printf("Erro no fsync do log de votos: %v\n", err)
+		falhaPersistencia(err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import "strings"
+
+//
+// Catálogo de mensagens por idioma.
+//
+// Confirmações e erros carregam um código estável (campo "codigo") e o
+// texto no idioma pedido pelo cliente. Idiomas desconhecidos caem no
+// idioma base ("en-US" -> "en") e, por fim, em pt-BR.
+//
+
+// Códigos das mensagens enviadas a um usuário.
+const (
+	CodVotoRegistrado = "VOTE_OK"
+	CodJaVotou        = "ALREADY_VOTED"
+	CodOpcaoInvalida  = "INVALID_OPTION"
+	CodSelecoesDemais = "TOO_MANY_OPTIONS"
+	CodNaoElegivel    = "NOT_ELIGIBLE"
+	CodPausada        = "PAUSED"
+	CodCedoDemais     = "TOO_SOON"
+	CodOpcaoLotada    = "OPTION_FULL"
+	CodEncerrada      = "CLOSED"
+	CodSemSeq         = "SEQ_REQUIRED"
+	CodSeqAntiga      = "STALE_SEQ"
+	CodNaoIniciada    = "NOT_STARTED"
+	CodSemIdentidade  = "IDENTITY_REQUIRED"
+
+	// Voto por procuração (delegacao.go).
+	CodDelegacaoNegada = "DELEGATION_DENIED"
+	CodDelegado        = "DELEGATED"
+
+	// Controles ou UTF-8 inválido no voto (caracteres.go).
+	CodCaracteres = "INVALID_CHARS"
+	// Limite de votantes distintos (MAX_VOTERS).
+	CodCapacidade = "CAPACITY"
+
+	// Prova de trabalho ausente ou abaixo da dificuldade (pow.go).
+	CodProvaTrabalho = "POW_REQUIRED"
+)
+
+const idiomaPadrao = "pt-BR"
+
+var catalogo = map[string]map[string]string{
+	"pt-BR": {
+		CodVotoRegistrado: "Voto registrado com sucesso.",
+		CodJaVotou:        "Você já votou.",
+		CodOpcaoInvalida:  "Opção inválida.",
+		CodSelecoesDemais: "Opções demais neste voto.",
+		CodNaoElegivel:    "Você não está na lista de eleitores.",
+		CodPausada:        "A votação está pausada. Tente novamente em instantes.",
+		CodCedoDemais:     "Aguarde um pouco antes de mudar o voto de novo.",
+		CodOpcaoLotada:    "Esta opção não tem mais vagas.",
+		CodEncerrada:      "A votação já foi encerrada.",
+		CodSemSeq:         "O voto precisa informar o número de sequência.",
+		CodSeqAntiga:      "Voto ignorado: um voto mais recente seu já foi registrado.",
+		CodNaoIniciada:    "A votação ainda não começou.",
+		CodSemIdentidade:  "Esta votação exige identificação por certificado.",
+
+		CodDelegacaoNegada: "Você não tem procuração para votar por um dos usuários informados.",
+		CodDelegado:        "Seu voto já foi registrado por procuração.",
+		CodCaracteres:      "Voto com caracteres inválidos.",
+		CodCapacidade:      "A votação atingiu o limite de participantes.",
+
+		CodProvaTrabalho: "Este voto exige uma prova de trabalho válida.",
+	},
+	"en": {
+		CodVotoRegistrado: "Vote recorded successfully.",
+		CodJaVotou:        "You have already voted.",
+		CodOpcaoInvalida:  "Invalid option.",
+		CodSelecoesDemais: "Too many options in this vote.",
+		CodNaoElegivel:    "You are not on the list of eligible voters.",
+		CodPausada:        "Voting is paused. Please try again shortly.",
+		CodCedoDemais:     "Please wait a moment before changing your vote again.",
+		CodOpcaoLotada:    "This option is full.",
+		CodEncerrada:      "Voting has already closed.",
+		CodSemSeq:         "The vote must include a sequence number.",
+		CodSeqAntiga:      "Vote ignored: a more recent vote of yours was already recorded.",
+		CodNaoIniciada:    "Voting has not started yet.",
+		CodSemIdentidade:  "This vote requires certificate-based identification.",
+
+		CodDelegacaoNegada: "You are not authorized to vote on behalf of one of the given users.",
+		CodDelegado:        "Your vote has already been cast by proxy.",
+		CodCaracteres:      "Vote contains invalid characters.",
+		CodCapacidade:      "This vote has reached its participant limit.",
+
+		CodProvaTrabalho: "This vote requires a valid proof of work.",
+	},
+	"es": {
+		CodVotoRegistrado: "Voto registrado con éxito.",
+		CodJaVotou:        "Ya has votado.",
+		CodOpcaoInvalida:  "Opción inválida.",
+		CodSelecoesDemais: "Demasiadas opciones en este voto.",
+		CodNaoElegivel:    "No estás en la lista de votantes.",
+		CodPausada:        "La votación está en pausa. Inténtalo de nuevo en breve.",
+		CodCedoDemais:     "Espera un momento antes de cambiar tu voto otra vez.",
+		CodOpcaoLotada:    "Esta opción ya no tiene plazas.",
+		CodEncerrada:      "La votación ya ha terminado.",
+		CodSemSeq:         "El voto debe incluir el número de secuencia.",
+		CodSeqAntiga:      "Voto ignorado: ya se registró un voto tuyo más reciente.",
+		CodNaoIniciada:    "La votación aún no ha comenzado.",
+		CodSemIdentidade:  "Esta votación exige identificación por certificado.",
+
+		CodDelegacaoNegada: "No tiene autorización para votar en nombre de uno de los usuarios indicados.",
+		CodDelegado:        "Su voto ya fue emitido por delegación.",
+		CodCaracteres:      "Voto con caracteres no válidos.",
+		CodCapacidade:      "La votación alcanzó el límite de participantes.",
+
+		CodProvaTrabalho: "Este voto requiere una prueba de trabajo válida.",
+	},
+}
+
+// Retorna o texto do código no idioma pedido, com fallback para pt-BR.
+func mensagem(lang, codigo string) string {
+	if textos, ok := catalogo[lang]; ok {
+		if t, ok := textos[codigo]; ok {
+			return t
+		}
+	}
+	if base, _, found := strings.Cut(lang, "-"); found {
+		if t, ok := catalogo[base][codigo]; ok {
+			return t
+		}
+	}
+	if t, ok := catalogo[idiomaPadrao][codigo]; ok {
+		return t
+	}
+	return codigo
+}
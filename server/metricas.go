@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Histogramas de GET /metrics, no formato de texto do Prometheus.
+var (
+	// Espera pelo amqpMu em publishJSON.
+	esperaAmqpMu = novoHistograma()
+	// Duração do PublishWithContext em publishJSON, já com o Lock.
+	duracaoPublish = novoHistograma()
+)
+
+// Limites superiores dos buckets, em segundos: de 50µs a 2,5s.
+var bucketsSegundos = []float64{0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// Histograma de durações com buckets fixos e contadores atômicos, para
+// ser atualizado por vários workers sem Lock.
+type Histograma struct {
+	// Contagem por bucket (não acumulada); o último é o +Inf.
+	buckets []atomic.Uint64
+	somaNs  atomic.Int64
+}
+
+func novoHistograma() *Histograma {
+	return &Histograma{buckets: make([]atomic.Uint64, len(bucketsSegundos)+1)}
+}
+
+// Registra uma duração.
+func (h *Histograma) observar(d time.Duration) {
+	s := d.Seconds()
+	i := 0
+	for i < len(bucketsSegundos) && s > bucketsSegundos[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+	h.somaNs.Add(int64(d))
+}
+
+// Escreve o histograma com buckets acumulados, soma e contagem.
+func (h *Histograma) escrever(w io.Writer, nome, ajuda string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", nome, ajuda)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", nome)
+	var acumulado uint64
+	for i, limite := range bucketsSegundos {
+		acumulado += h.buckets[i].Load()
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", nome, strconv.FormatFloat(limite, 'g', -1, 64), acumulado)
+	}
+	acumulado += h.buckets[len(bucketsSegundos)].Load()
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", nome, acumulado)
+	fmt.Fprintf(w, "%s_sum %g\n", nome, time.Duration(h.somaNs.Load()).Seconds())
+	fmt.Fprintf(w, "%s_count %d\n", nome, acumulado)
+}
@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+//
+// Modo de apuração (TALLY).
+//
+// plurality (padrão): cada voto escolhe uma opção, ou até MAX_SELECTIONS
+// opções em múltipla escolha, e CAPS/vagas limitam quantos votos cada
+// opção comporta (alocação de vagas: quem chega primeiro ocupa).
+//
+// irv: ranking com segundo turno instantâneo (ver irv.go).
+//
+// approval: cada eleitor aprova qualquer subconjunto das opções, sem
+// limite de seleções nem de vagas. Cada opção aprovada soma um, o
+// usuário continua sendo deduplicado pelo conjunto inteiro (um revoto
+// troca o conjunto) e vence a opção mais aprovada. Os percentuais são
+// sobre os votantes, não sobre o total de marcações: 60% em A quer dizer
+// que 60% dos eleitores aprovaram A, e as opções podem somar mais de 100%.
+//
+
+const (
+	ApuracaoMaioria   = "plurality"
+	ApuracaoAprovacao = "approval"
+	ApuracaoRanking   = "irv"
+)
+
+// Modo de apuração da votação, anunciado no "opcoes", nas parciais e no
+// final (campo "apuracao", omitido no padrão).
+var modoApuracao = ApuracaoMaioria
+
+func lerModoApuracao(v string) (string, error) {
+	switch v {
+	case "", ApuracaoMaioria:
+		return ApuracaoMaioria, nil
+	case ApuracaoAprovacao, ApuracaoRanking:
+		return v, nil
+	}
+	return "", fmt.Errorf("TALLY inválido: %q (use plurality, approval ou irv)", v)
+}
+
+// Quantidade de opções: o maior voto possível na aprovação e no ranking.
+func totalOpcoes(validador Validador) int {
+	if r, ok := validador.(*intervaloOpcoes); ok {
+		return r.max - r.min + 1
+	}
+	return len(validador.Iniciais())
+}
+
+// Fora da maioria simples, parciais e final levam o modo e os votantes,
+// a base dos percentuais na aprovação.
+func preencherModo(msg *BroadcastMsg, votantes int64) {
+	if modoApuracao == ApuracaoMaioria {
+		return
+	}
+	msg.Apuracao = modoApuracao
+	msg.Votantes = int(votantes)
+}
@@ -0,0 +1,22 @@
+package main
+
+// Nomes das exchanges e da fila de votos. EXCHANGE_PREFIX troca o prefixo
+// "votacao" para isolar votações de equipes diferentes no mesmo broker
+// (ex.: "acme" -> acme.votos, acme.broadcast, fila acme.votos).
+var (
+	exchangeVotos     = "votacao.votos"
+	exchangeBroadcast = "votacao.broadcast"
+	exchangeControle  = "votacao.controle"
+	exchangeAudit     = "votacao.audit"
+	filaVotos         = "votos"
+)
+
+// Aplica o prefixo a todos os nomes. A fila também recebe o prefixo, para
+// que duas votações não disputem os mesmos votos.
+func aplicarPrefixo(prefixo string) {
+	exchangeVotos = prefixo + ".votos"
+	exchangeBroadcast = prefixo + ".broadcast"
+	exchangeControle = prefixo + ".controle"
+	exchangeAudit = prefixo + ".audit"
+	filaVotos = prefixo + ".votos"
+}
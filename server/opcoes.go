@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+// Validação das opções de voto.
+//
+
+// Validador decide quais opções são aceitas pela votação.
+type Validador interface {
+	// Valid informa se a opção recebida em um voto é aceita.
+	Valid(opcao string) bool
+	// Iniciais retorna as chaves com que a contagem começa zerada.
+	// Modos com muitas opções (intervalos) retornam vazio e as chaves
+	// são criadas conforme os votos chegam.
+	Iniciais() []string
+}
+
+// Conjunto fixo de opções, ex.: "A,B,C".
+type conjuntoOpcoes struct {
+	ordem      []string
+	permitidas map[string]bool
+	// Rótulos e cores de exibição por chave (arquivo de opções).
+	rotulos map[string]string
+	cores   map[string]string
+}
+
+func (c *conjuntoOpcoes) Valid(opcao string) bool {
+	return c.permitidas[opcao]
+}
+
+func (c *conjuntoOpcoes) Iniciais() []string {
+	return c.ordem
+}
+
+// Intervalo numérico de opções, ex.: "RANGE:1-10".
+type intervaloOpcoes struct {
+	min, max int
+}
+
+func (r *intervaloOpcoes) Valid(opcao string) bool {
+	n, err := strconv.Atoi(opcao)
+	if err != nil {
+		return false
+	}
+	// Exige a forma canônica ("7", não "07") para não dividir a
+	// contagem da mesma opção em chaves diferentes.
+	if strconv.Itoa(n) != opcao {
+		return false
+	}
+	return n >= r.min && n <= r.max
+}
+
+func (r *intervaloOpcoes) Iniciais() []string {
+	return nil
+}
+
+// Cria o validador a partir da especificação configurada:
+// uma lista separada por vírgulas ou "RANGE:min-max".
+func novoValidador(spec string) (Validador, error) {
+	spec = strings.TrimSpace(spec)
+
+	if rest, ok := strings.CutPrefix(spec, "RANGE:"); ok {
+		// Só valores não negativos: o "-" separa os limites, aqui e no
+		// campo "intervalo" que os clientes leem.
+		if strings.Count(rest, "-") > 1 || strings.HasPrefix(strings.TrimSpace(rest), "-") {
+			return nil, fmt.Errorf("intervalo inválido %q: só valores não negativos (ex.: RANGE:0-10)", spec)
+		}
+		minStr, maxStr, found := strings.Cut(rest, "-")
+		if !found {
+			return nil, fmt.Errorf("intervalo inválido %q (esperado RANGE:min-max)", spec)
+		}
+		min, err1 := strconv.Atoi(strings.TrimSpace(minStr))
+		max, err2 := strconv.Atoi(strings.TrimSpace(maxStr))
+		if err1 != nil || err2 != nil || min > max {
+			return nil, fmt.Errorf("intervalo inválido %q (esperado RANGE:min-max)", spec)
+		}
+		return &intervaloOpcoes{min: min, max: max}, nil
+	}
+
+	c := &conjuntoOpcoes{permitidas: map[string]bool{}}
+	for _, op := range strings.Split(spec, ",") {
+		op = strings.TrimSpace(op)
+		if op == "" || c.permitidas[op] {
+			continue
+		}
+		c.permitidas[op] = true
+		c.ordem = append(c.ordem, op)
+	}
+	if len(c.ordem) == 0 {
+		return nil, fmt.Errorf("nenhuma opção configurada em %q", spec)
+	}
+	return c, nil
+}
+
+// Valida as opções de um voto e retorna o código de erro, ou "" se o
+// voto for aceito. Opções repetidas no mesmo voto são inválidas.
+func validarEscolhas(validador Validador, escolhas []string, maxSelecoes int) string {
+	if len(escolhas) > maxSelecoes {
+		return CodSelecoesDemais
+	}
+	vistas := make(map[string]bool, len(escolhas))
+	for _, op := range escolhas {
+		if !validador.Valid(op) || vistas[op] {
+			return CodOpcaoInvalida
+		}
+		vistas[op] = true
+	}
+	return ""
+}
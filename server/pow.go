@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//
+// Prova de trabalho nos votos (POW_DIFFICULTY).
+//
+// Em enquetes abertas, sem contas de usuário, nada impede um script de
+// gerar milhares de UserIDs. Com dificuldade N, cada voto precisa trazer
+// em "pow" um texto tal que sha256(userId + ":" + pow) comece com N bits
+// zero: o cliente testa em média 2^N valores, o servidor confere com um
+// hash só. O userId é o que o servidor conta (a cédula "cedula:<hash>"
+// nos votos anônimos, o do certificado com VOTER_IDENTITY=cert), então
+// uma prova não serve para outro usuário. Não impede quem tem CPU de
+// sobra; só encarece votos falsos em massa.
+//
+// A conferência é a votacao.ProvaValida da biblioteca, a mesma regra do
+// votacao.ProvaDeTrabalho que os clientes usam para resolver.
+//
+
+// Bits zero exigidos no hash; 0 desliga a verificação.
+var dificuldadePoW int
+
+// Maior dificuldade aceita: acima disso, nenhum cliente vota a tempo.
+const maxDificuldadePoW = 32
+
+// Lê POW_DIFFICULTY; vazio vale 0.
+func lerDificuldadePoW(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > maxDificuldadePoW {
+		return 0, fmt.Errorf("POW_DIFFICULTY inválido: %q (use de 0 a %d)", v, maxDificuldadePoW)
+	}
+	return n, nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Prazo controla o tempo restante da votação. Diferente de um
+// time.Sleep, pode ser suspenso (pausa) e retomado sem perder o tempo
+// que faltava.
+type Prazo struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	fim      time.Time     // Momento em que expira, enquanto corre.
+	restante time.Duration // Tempo que faltava quando foi pausado.
+	pausado  atomic.Bool
+	expirou  chan struct{}
+	fechar   sync.Once
+	// Início da votação (atualizado no reset) e motivo do encerramento.
+	inicio time.Time
+	motivo string
+	// Votação agendada (VOTING_START): aberta fica falsa e abriu aberto
+	// até o início.
+	aberta   atomic.Bool
+	abriu    chan struct{}
+	abrir    sync.Once
+	abertura *time.Timer
+	// Encerramento por inatividade (IDLE_TIMEOUT); zero desliga. O
+	// último voto aceito fica num atômico para os workers não disputarem
+	// o mutex: o timer confere o tempo parado quando dispara.
+	inatividade time.Duration
+	ultimoVoto  atomic.Int64
+	ocioso      *time.Timer
+}
+
+// Motivos de encerramento informados no "final".
+const (
+	MotivoTimeout     = "timeout"
+	MotivoAdmin       = "admin"
+	MotivoInatividade = "idle"
+)
+
+// Inicia um prazo que expira após a duração informada.
+func novoPrazo(d time.Duration) *Prazo {
+	return novoPrazoAgendado(time.Now(), d)
+}
+
+// Prazo de uma votação que abre em inicio e dura d a partir dele. Com
+// inicio no passado, a votação abre agora.
+func novoPrazoAgendado(inicio time.Time, d time.Duration) *Prazo {
+	p := &Prazo{expirou: make(chan struct{}), abriu: make(chan struct{})}
+	if agora := time.Now(); inicio.Before(agora) {
+		inicio = agora
+	}
+	p.inicio = inicio
+	p.fim = inicio.Add(d)
+	p.timer = time.AfterFunc(time.Until(p.fim), p.esgotar)
+	if espera := time.Until(inicio); espera > 0 {
+		p.abertura = time.AfterFunc(espera, p.liberar)
+	} else {
+		p.liberar()
+	}
+	return p
+}
+
+// Prazo retomado de um estado gravado (-restore-state): mantém o início
+// original e expira em fim, já no passado se o prazo venceu durante a
+// troca de servidor. Pausado, guarda o restante até o "resume".
+func restaurarPrazo(inicio, fim time.Time, pausado bool, restante time.Duration) *Prazo {
+	p := &Prazo{expirou: make(chan struct{}), abriu: make(chan struct{}), inicio: inicio}
+	if pausado {
+		p.restante = restante
+		p.pausado.Store(true)
+		p.timer = time.AfterFunc(restante, p.esgotar)
+		p.timer.Stop()
+	} else {
+		p.fim = fim
+		p.timer = time.AfterFunc(time.Until(fim), p.esgotar)
+	}
+	if espera := time.Until(inicio); espera > 0 {
+		p.abertura = time.AfterFunc(espera, p.liberar)
+	} else {
+		p.liberar()
+	}
+	return p
+}
+
+// Abre a votação uma única vez (horário de início ou reset).
+func (p *Prazo) liberar() {
+	p.abrir.Do(func() {
+		p.aberta.Store(true)
+		close(p.abriu)
+	})
+}
+
+// Informa se a votação já abriu. Não usa o mutex, como Pausado.
+func (p *Prazo) Aberta() bool {
+	return p.aberta.Load()
+}
+
+// Canal fechado quando a votação abre.
+func (p *Prazo) Abriu() <-chan struct{} {
+	return p.abriu
+}
+
+// Horário de abertura e se ainda está por vir.
+func (p *Prazo) Abertura() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.inicio, !p.aberta.Load()
+}
+
+// Fecha o canal de expiração uma única vez (timer ou encerramento manual),
+// guardando o motivo de quem fechou primeiro.
+func (p *Prazo) expirar(motivo string) {
+	p.fechar.Do(func() {
+		p.motivo = motivo
+		close(p.expirou)
+	})
+}
+
+// Encerra a votação se ficar d sem votos aceitos, contando da abertura,
+// do último voto, do "resume" ou do "reset". Compõe com o prazo fixo: o
+// que vencer primeiro encerra.
+func (p *Prazo) VigiarInatividade(d time.Duration) {
+	go func() {
+		<-p.Abriu()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.inatividade = d
+		p.ultimoVoto.Store(time.Now().UnixNano())
+		p.ocioso = time.AfterFunc(d, p.verificarInatividade)
+	}()
+}
+
+// Marca a atividade da votação; chamado a cada voto aceito.
+func (p *Prazo) RegistrarVoto() {
+	p.ultimoVoto.Store(time.Now().UnixNano())
+}
+
+// Chamado pelo timer de inatividade: encerra se não houve voto no
+// intervalo ou volta a esperar o que falta. Pausada, não encerra; o
+// "resume" recomeça a contagem.
+func (p *Prazo) verificarInatividade() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pausado.Load() {
+		return
+	}
+	parado := time.Since(time.Unix(0, p.ultimoVoto.Load()))
+	if falta := p.inatividade - parado; falta > 0 {
+		p.ocioso.Reset(falta)
+		return
+	}
+	p.timer.Stop()
+	p.expirar(MotivoInatividade)
+}
+
+// Recomeça a contagem de inatividade, chamado com o mu travado.
+func (p *Prazo) reiniciarInatividade() {
+	if p.ocioso == nil {
+		return
+	}
+	p.ultimoVoto.Store(time.Now().UnixNano())
+	p.ocioso.Reset(p.inatividade)
+}
+
+// Chamado pelo timer quando o tempo acaba.
+func (p *Prazo) esgotar() {
+	p.expirar(MotivoTimeout)
+}
+
+// Encerra o prazo imediatamente, como se o tempo tivesse acabado.
+func (p *Prazo) Encerrar() {
+	p.encerrarCom(MotivoAdmin)
+}
+
+func (p *Prazo) encerrarCom(motivo string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.timer.Stop()
+	p.pausado.Store(false)
+	p.expirar(motivo)
+}
+
+// Recomeça o prazo com a duração informada, removendo uma eventual
+// pausa. Retorna false se o prazo já expirou.
+func (p *Prazo) Reiniciar(d time.Duration) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.expirou:
+		return time.Time{}, false
+	default:
+	}
+
+	p.timer.Stop()
+	p.inicio = time.Now()
+	p.fim = p.inicio.Add(d)
+	p.timer = time.AfterFunc(d, p.esgotar)
+	p.pausado.Store(false)
+	p.reiniciarInatividade()
+	// Um reset antes do horário agendado abre a votação agora.
+	if p.abertura != nil {
+		p.abertura.Stop()
+	}
+	p.liberar()
+	return p.fim, true
+}
+
+// Canal fechado quando o prazo termina.
+func (p *Prazo) Expirou() <-chan struct{} {
+	return p.expirou
+}
+
+// Retorna quando a votação começou e por que terminou. O motivo só é
+// válido depois que Expirou() fecha.
+func (p *Prazo) Encerramento() (time.Time, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.inicio, p.motivo
+}
+
+// Informa se a votação está pausada. Não usa o mutex, para ser barato
+// de consultar a cada voto.
+func (p *Prazo) Pausado() bool {
+	return p.pausado.Load()
+}
+
+// Retorna quando o prazo termina e se está pausado. Durante a pausa o
+// fim é estimado como agora + tempo restante.
+func (p *Prazo) Fim() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pausado.Load() {
+		return time.Now().Add(p.restante), true
+	}
+	return p.fim, false
+}
+
+// Início, fim e, pausado, o tempo restante, para o "dump-state".
+func (p *Prazo) Situacao() (inicio, fim time.Time, restante time.Duration, pausado bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.inicio, p.fim, p.restante, p.pausado.Load()
+}
+
+// Soma delta (negativo para encurtar) ao tempo restante, sem deixá-lo
+// ficar negativo: encurtar além do que falta encerra a votação agora,
+// como um prazo esgotado. Pausada, ajusta o restante e o fim estimado.
+// Retorna false antes da abertura ou depois do encerramento.
+func (p *Prazo) Ajustar(delta time.Duration) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.expirou:
+		return time.Time{}, false
+	default:
+	}
+	if !p.aberta.Load() {
+		return time.Time{}, false
+	}
+	if p.pausado.Load() {
+		p.restante = max(p.restante+delta, 0)
+		return time.Now().Add(p.restante), true
+	}
+	if !p.timer.Stop() {
+		return time.Time{}, false
+	}
+	restante := max(time.Until(p.fim)+delta, 0)
+	p.fim = time.Now().Add(restante)
+	p.timer = time.AfterFunc(restante, p.esgotar)
+	return p.fim, true
+}
+
+// Suspende a contagem do prazo. Retorna false se já estava pausado, se
+// a votação ainda não abriu ou se o prazo já expirou.
+func (p *Prazo) Pausar() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pausado.Load() || !p.aberta.Load() || !p.timer.Stop() {
+		return false
+	}
+	p.restante = time.Until(p.fim)
+	p.pausado.Store(true)
+	return true
+}
+
+// Retoma a contagem com o tempo que faltava e retorna o novo fim.
+// Retorna false se não estava pausado.
+func (p *Prazo) Retomar() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.pausado.Load() {
+		return time.Time{}, false
+	}
+	p.fim = time.Now().Add(p.restante)
+	p.timer = time.AfterFunc(p.restante, p.esgotar)
+	p.pausado.Store(false)
+	p.reiniciarInatividade()
+	return p.fim, true
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+//
+// Ajuste automático do prefetch (PREFETCH_AUTO=min:max).
+//
+// Um prefetch fixo raramente serve para a votação inteira: pequeno demais,
+// os workers ficam ociosos com votos esperando no broker; grande demais,
+// milhares de votos ficam parados no buffer do processo, sem ack, e
+// voltam todos para a fila se ele cair. A cada PREFETCH_AUTO_INTERVAL, o
+// ajuste compara a fila no broker com o tempo ocupado dos workers:
+//   - votos esperando e workers ociosos (< 80% do tempo): o buffer não
+//     acompanha, e o prefetch dobra;
+//   - votos esperando e workers saturados (> 95%): mais buffer não
+//     acelera nada, e o prefetch cai um quarto;
+//   - fila vazia: nada a ajustar.
+//
+// O limite passa a ser por canal (basic.qos global): no RabbitMQ, um novo
+// basic.qos por consumidor só vale para consumidores criados depois dele,
+// enquanto o do canal muda na hora. No canal principal, o consumidor de
+// votos é o único com ack manual, então o efeito é o mesmo.
+//
+
+// Tempo ocupado somado pelos workers, lido pelo ajuste automático.
+type Ocupacao struct {
+	nanos atomic.Int64
+}
+
+func (o *Ocupacao) somar(d time.Duration) {
+	o.nanos.Add(int64(d))
+}
+
+// Faixa e estado do ajuste automático.
+type AjustePrefetch struct {
+	min, max int
+	atual    int
+	workers  int
+	ocupacao *Ocupacao
+}
+
+// Lê PREFETCH_AUTO no formato "min:max"; vazio desliga o ajuste.
+func lerAjustePrefetch(spec string) (min, max int, err error) {
+	if spec == "" {
+		return 0, 0, nil
+	}
+	a, b, ok := strings.Cut(spec, ":")
+	min, err1 := strconv.Atoi(a)
+	max, err2 := strconv.Atoi(b)
+	if !ok || err1 != nil || err2 != nil || min <= 0 || max < min {
+		return 0, 0, fmt.Errorf("PREFETCH_AUTO inválido: %q (use min:max, ex.: 10:500)", spec)
+	}
+	return min, max, nil
+}
+
+// Próximo prefetch a partir da fila no broker e da fração do tempo em que
+// os workers estiveram ocupados no último intervalo.
+func (a *AjustePrefetch) proximo(naFila int, utilizacao float64) int {
+	if naFila == 0 {
+		return a.atual
+	}
+	switch {
+	case utilizacao < 0.8:
+		return min(a.atual*2, a.max)
+	case utilizacao > 0.95:
+		return max(a.atual-a.atual/4, a.min)
+	}
+	return a.atual
+}
+
+// Observa a fila e os workers a cada intervalo e aplica o novo prefetch
+// no canal de consumo. A fila é lida num canal próprio, porque um erro na
+// declaração passiva fecha o canal.
+func (a *AjustePrefetch) executar(conn *amqp.Connection, ch *amqp.Channel, fila string, intervalo time.Duration) {
+	var obs *amqp.Channel
+	anterior := a.ocupacao.nanos.Load()
+	t := time.NewTicker(intervalo)
+	defer t.Stop()
+	for range t.C {
+		ocupado := a.ocupacao.nanos.Load()
+		utilizacao := float64(ocupado-anterior) / float64(int64(intervalo)*int64(a.workers))
+		anterior = ocupado
+
+		if obs == nil || obs.IsClosed() {
+			var err error
+			if obs, err = conn.Channel(); err != nil {
+				if conn.IsClosed() {
+					return
+				}
+				continue
+			}
+		}
+		q, err := obs.QueueDeclarePassive(fila, false, false, false, false, nil)
+		if err != nil {
+			continue
+		}
+
+		novo := a.proximo(q.Messages, utilizacao)
+		if novo == a.atual {
+			continue
+		}
+		if err := ch.Qos(novo, 0, true); err != nil {
+			log.Printf("Erro ao ajustar o prefetch: %v\n", err)
+			return
+		}
+		log.Printf("Prefetch ajustado: %d -> %d (%d votos na fila, workers %.0f%% ocupados)\n", a.atual, novo, q.Messages, utilizacao*100)
+		a.atual = novo
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+//
+// Recibos de voto assinados com HMAC-SHA256 (RECEIPT_SECRET).
+//
+
+// Recibo enviado na confirmação. Com o segredo do servidor, qualquer
+// auditor refaz o HMAC a partir dos campos públicos e confirma que o voto
+// foi contado sem alteração.
+type Recibo struct {
+	Opcao     string `json:"opcao"`
+	Timestamp string `json:"timestamp"`
+	HMAC      string `json:"hmac"`
+}
+
+// Segredo dos recibos; vazio desliga o recurso.
+var segredoRecibo []byte
+
+// Mensagem assinada: userId, opções (separadas por vírgula) e horário,
+// um por linha.
+func mensagemRecibo(userID, opcao, timestamp string) []byte {
+	return []byte(userID + "\n" + opcao + "\n" + timestamp)
+}
+
+// Gera o recibo de um voto aceito. Retorna nil sem segredo configurado.
+func gerarRecibo(segredo []byte, userID string, escolhas []string, quando time.Time) *Recibo {
+	if len(segredo) == 0 {
+		return nil
+	}
+	r := &Recibo{
+		Opcao:     strings.Join(escolhas, ","),
+		Timestamp: quando.UTC().Format(time.RFC3339Nano),
+	}
+	mac := hmac.New(sha256.New, segredo)
+	mac.Write(mensagemRecibo(userID, r.Opcao, r.Timestamp))
+	r.HMAC = hex.EncodeToString(mac.Sum(nil))
+	return r
+}
+
+// Confere se o recibo corresponde ao usuário e foi assinado com o segredo.
+func verificarRecibo(segredo []byte, userID string, r Recibo) bool {
+	assinatura, err := hex.DecodeString(r.HMAC)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, segredo)
+	mac.Write(mensagemRecibo(userID, r.Opcao, r.Timestamp))
+	return hmac.Equal(assinatura, mac.Sum(nil))
+}
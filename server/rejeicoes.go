@@ -0,0 +1,81 @@
+package main
+
+import "sync/atomic"
+
+// Motivos de rejeição contados para o relatório do "final".
+const (
+	MotivoDuplicado  = "duplicado"
+	MotivoInvalido   = "invalido"
+	MotivoInelegivel = "inelegivel"
+	MotivoEncerrada  = "encerrada"
+	MotivoLotada     = "lotada"
+)
+
+// Motivo de cada código de erro. Repetições do mesmo usuário contam como
+// duplicadas; votos fora do período de votação (pausa incluída), como
+// encerrada.
+var motivoDoCodigo = map[string]string{
+	CodJaVotou:        MotivoDuplicado,
+	CodCedoDemais:     MotivoDuplicado,
+	CodSeqAntiga:      MotivoDuplicado,
+	CodOpcaoInvalida:  MotivoInvalido,
+	CodSelecoesDemais: MotivoInvalido,
+	CodSemSeq:         MotivoInvalido,
+	CodNaoElegivel:    MotivoInelegivel,
+	CodSemIdentidade:  MotivoInelegivel,
+	CodEncerrada:      MotivoEncerrada,
+	CodPausada:        MotivoEncerrada,
+	CodNaoIniciada:    MotivoEncerrada,
+	CodOpcaoLotada:    MotivoLotada,
+
+	CodDelegacaoNegada: MotivoInelegivel,
+	CodDelegado:        MotivoDuplicado,
+	CodCaracteres:      MotivoInvalido,
+	CodCapacidade:      MotivoLotada,
+
+	CodProvaTrabalho: MotivoInvalido,
+}
+
+// Contadores de rejeição por motivo. O mapa é montado na criação e só
+// os valores mudam, então pode ser lido sem Lock.
+type Rejeicoes map[string]*atomic.Int64
+
+func novasRejeicoes() Rejeicoes {
+	r := Rejeicoes{}
+	for _, motivo := range motivoDoCodigo {
+		r[motivo] = &atomic.Int64{}
+	}
+	return r
+}
+
+// Conta uma rejeição pelo código de erro devolvido ao usuário.
+func (r Rejeicoes) contarCodigo(codigo string) {
+	if motivo, ok := motivoDoCodigo[codigo]; ok {
+		r[motivo].Add(1)
+	}
+}
+
+// Conta uma rejeição direto pelo motivo (ex.: corpo que não decodifica).
+func (r Rejeicoes) contar(motivo string) {
+	r[motivo].Add(1)
+}
+
+// Cópia dos motivos com pelo menos uma rejeição; nil sem nenhuma.
+func (r Rejeicoes) copia() map[string]int {
+	var m map[string]int
+	for motivo, n := range r {
+		if v := n.Load(); v > 0 {
+			if m == nil {
+				m = map[string]int{}
+			}
+			m[motivo] = int(v)
+		}
+	}
+	return m
+}
+
+func (r Rejeicoes) zerar() {
+	for _, n := range r {
+		n.Store(0)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"votacao-rabbitmq/client/votacao"
+)
+
+//
+// Modo -standalone: servidor e um cliente interativo no mesmo processo.
+//
+// Para demonstrações, basta o broker e um único binário. O cliente usa a
+// biblioteca votacao sobre a mesma conexão do servidor: os votos passam
+// pela exchange de votos e os resultados chegam pelo broadcast, como
+// para qualquer outro cliente.
+//
+
+// Demo é o cliente interativo embutido.
+type Demo struct {
+	cliente *votacao.Cliente
+	// Fechado quando o resultado final foi exibido.
+	fim chan struct{}
+}
+
+// Assina o broadcast e começa a ler votos do terminal.
+func iniciarDemo(conn *amqp.Connection, validador Validador) (*Demo, error) {
+	prefixo := strings.TrimSuffix(exchangeVotos, ".votos")
+	d := &Demo{cliente: votacao.NovoCliente(conn, prefixo), fim: make(chan struct{})}
+
+	eventos, err := d.cliente.Subscribe(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	go d.mostrarEventos(eventos)
+	go d.lerVotos(validador)
+	return d, nil
+}
+
+// Imprime parciais, confirmações, erros e o final.
+func (d *Demo) mostrarEventos(eventos <-chan votacao.Event) {
+	defer close(d.fim)
+	for ev := range eventos {
+		switch e := ev.(type) {
+		case votacao.PartialResult:
+			fmt.Println("\nParcial da votação:")
+			mostrarResultados(e.Resultados)
+		case votacao.Confirmation:
+			fmt.Printf("\n%s: %s (%s)\n", e.UserID, e.Mensagem, strings.Join(e.Opcoes, ","))
+		case *votacao.Error:
+			fmt.Printf("\n%s: %s\n", e.UserID, e.Mensagem)
+		case votacao.FinalResult:
+			fmt.Println("\nResultado final da votação:")
+			mostrarResultados(e.Resultados)
+			switch {
+			case e.Empate:
+				fmt.Println("\nResultado: empate.")
+			case e.Vencedor != "":
+				fmt.Printf("\nVencedor: %s\n", e.Vencedor)
+			}
+			return
+		}
+		fmt.Print("Voto (usuário opção): ")
+	}
+}
+
+func mostrarResultados(resultados []votacao.ResultadoOpcao) {
+	for _, r := range resultados {
+		fmt.Printf("  %s: %d votos (%.1f%%)\n", r.Opcao, r.Votos, r.Percentual)
+	}
+}
+
+// Cada linha do terminal é um voto "usuário opção"; opções múltiplas
+// vão separadas por vírgula ("ana A,C").
+func (d *Demo) lerVotos(validador Validador) {
+	fmt.Printf("\nModo standalone. Opções: %s\n", strings.Join(validador.Iniciais(), ", "))
+	fmt.Print("Voto (usuário opção): ")
+
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		campos := strings.Fields(sc.Text())
+		if len(campos) != 2 {
+			fmt.Print("Use: usuário opção\nVoto (usuário opção): ")
+			continue
+		}
+		v := votacao.Voto{UserID: campos[0], Lang: idiomaPadrao}
+		if opcoes := strings.Split(campos[1], ","); len(opcoes) > 1 {
+			v.Options = opcoes
+		} else {
+			v.Option = opcoes[0]
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		if err := d.cliente.Vote(ctx, v); err != nil {
+			log.Printf("Erro ao enviar voto: %v\n", err)
+		}
+		cancel()
+	}
+}
+
+// Dá tempo ao cliente de exibir o resultado final antes do os.Exit.
+func (d *Demo) aguardar(limite time.Duration) {
+	select {
+	case <-d.fim:
+	case <-time.After(limite):
+	}
+}
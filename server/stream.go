@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//
+// Stream de resultados por Server-Sent Events (GET /stream).
+//
+// enviarParcial e enviarFinal, além de publicar no broadcast AMQP,
+// repassam a mensagem ao Difusor, que a entrega a cada cliente SSE
+// conectado.
+//
+
+// Evento entregue aos clientes SSE.
+type eventoSSE struct {
+	tipo  string
+	dados []byte
+}
+
+// Eventos retidos por cliente; um cliente lento perde parciais em vez
+// de atrasar os workers (o final sempre entra).
+const bufferSSE = 16
+
+// Intervalo dos comentários de keep-alive, que também revelam clientes
+// desconectados.
+const keepAliveSSE = 15 * time.Second
+
+// Difusor distribui as parciais e o final aos clientes SSE conectados.
+type Difusor struct {
+	mu         sync.Mutex
+	assinantes map[chan eventoSSE]struct{}
+}
+
+// Feed interno alimentado pelos envios de parcial e final.
+var difusao = &Difusor{assinantes: map[chan eventoSSE]struct{}{}}
+
+func (d *Difusor) assinar() chan eventoSSE {
+	c := make(chan eventoSSE, bufferSSE)
+	d.mu.Lock()
+	d.assinantes[c] = struct{}{}
+	d.mu.Unlock()
+	return c
+}
+
+func (d *Difusor) cancelar(c chan eventoSSE) {
+	d.mu.Lock()
+	delete(d.assinantes, c)
+	d.mu.Unlock()
+}
+
+// Entrega a mensagem a todos os assinantes sem bloquear.
+func (d *Difusor) publicar(msg BroadcastMsg) {
+	dados, _ := json.Marshal(msg)
+	ev := eventoSSE{tipo: msg.Tipo, dados: dados}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for c := range d.assinantes {
+		select {
+		case c <- ev:
+			continue
+		default:
+		}
+		// O final nunca é descartado: abre espaço tirando a parcial
+		// mais antiga do buffer.
+		if msg.Tipo == "final" {
+			select {
+			case <-c:
+			default:
+			}
+			select {
+			case c <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// Mantém a resposta aberta enviando cada parcial e o final como eventos
+// SSE, começando pela contagem atual.
+func servirStream(apuracao *Apuracao) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fl, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming não suportado", http.StatusInternalServerError)
+			return
+		}
+
+		c := difusao.assinar()
+		defer difusao.cancelar(c)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		// Com HIDE_PARTIALS o stream só recebe o final.
+		if !ocultarParciais {
+			atual := apuracao.parcialCompleta()
+			atual.Tipo = "parcial"
+			dados, _ := json.Marshal(atual)
+			fmt.Fprintf(w, "event: parcial\ndata: %s\n\n", dados)
+		}
+		fl.Flush()
+
+		ping := time.NewTicker(keepAliveSSE)
+		defer ping.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ping.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case ev := <-c:
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.tipo, ev.dados)
+			}
+			fl.Flush()
+		}
+	}
+}
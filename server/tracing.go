@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+//
+// Rastreamento OpenTelemetry do ciclo de vida do voto.
+//
+// O cliente abre o span ao publicar e injeta o contexto nos headers AMQP;
+// o worker continua o mesmo trace. O exportador OTLP/HTTP é configurado
+// pelas variáveis padrão (OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_SERVICE_NAME
+// etc.); sem endpoint definido, o tracer global é um no-op.
+//
+
+var tracer = otel.Tracer("votacao-rabbitmq/server")
+
+// Descarrega os spans pendentes; trocada por iniciarTracing.
+var finalizarTracing = func() {}
+
+// Configura o exportador OTLP quando há endpoint nas variáveis padrão.
+func iniciarTracing() {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return
+	}
+
+	exp, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		log.Printf("Tracing desabilitado: %v\n", err)
+		return
+	}
+
+	// OTEL_SERVICE_NAME, quando definido, tem precedência sobre o padrão.
+	res, _ := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName("votacao-server")),
+		resource.Environment(),
+	)
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	finalizarTracing = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		tp.Shutdown(ctx)
+	}
+	log.Println("Tracing OpenTelemetry habilitado (OTLP/HTTP).")
+}
+
+// Adapta os headers AMQP ao TextMapCarrier do OpenTelemetry.
+type cabecalhosAMQP amqp.Table
+
+func (c cabecalhosAMQP) Get(chave string) string {
+	v, _ := c[chave].(string)
+	return v
+}
+
+func (c cabecalhosAMQP) Set(chave, valor string) {
+	c[chave] = valor
+}
+
+func (c cabecalhosAMQP) Keys() []string {
+	chaves := make([]string, 0, len(c))
+	for k := range c {
+		chaves = append(chaves, k)
+	}
+	return chaves
+}
+
+// Recupera o contexto de trace propagado pelo cliente nos headers.
+func contextoDaEntrega(msg amqp.Delivery) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), cabecalhosAMQP(msg.Headers))
+}
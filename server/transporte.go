@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+//
+// Transporte das mensagens.
+//
+// O servidor só publica e consome pelo Transport. Em produção ele é o
+// próprio *amqp.Channel; nos testes, o transporte em memória roda o fluxo
+// voto -> contagem -> broadcast sem RabbitMQ, inclusive com um cliente da
+// biblioteca votacao (NovoClienteTransporte), que tem a mesma interface.
+//
+
+// Transport cobre as duas operações usadas pela lógica de votação, com as
+// mesmas assinaturas do *amqp.Channel.
+type Transport interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+}
+
+var _ Transport = (*amqp.Channel)(nil)
+
+// Transporte em processo baseado em channels. Uma fila vinculada com
+// Vincular recebe toda mensagem publicada na exchange, como num fanout;
+// com VincularRota, só as de routing key que casam com o padrão, como
+// numa exchange topic. Cada fila recebe no máximo uma cópia por
+// mensagem, mesmo com vários vínculos. Publicações na exchange padrão ("")
+// vão direto para a fila com o nome da routing key, como no AMQP.
+type transporteMemoria struct {
+	mu       sync.Mutex
+	filas    map[string]chan amqp.Delivery
+	vinculos map[string][]vinculoMemoria
+}
+
+// Vínculo de uma fila a uma exchange; todas ignora a rota.
+type vinculoMemoria struct {
+	fila  string
+	rota  string
+	todas bool
+}
+
+func novoTransporteMemoria() *transporteMemoria {
+	return &transporteMemoria{
+		filas:    map[string]chan amqp.Delivery{},
+		vinculos: map[string][]vinculoMemoria{},
+	}
+}
+
+// Cria a fila, se preciso, e a vincula à exchange.
+func (t *transporteMemoria) Vincular(fila, exchange string) {
+	t.vincular(vinculoMemoria{fila: fila, todas: true}, exchange)
+}
+
+// Cria a fila, se preciso, e a vincula à exchange pelo padrão de rota de
+// uma exchange topic ("*" casa uma palavra, "#" zero ou mais).
+func (t *transporteMemoria) VincularRota(fila, exchange, rota string) {
+	t.vincular(vinculoMemoria{fila: fila, rota: rota}, exchange)
+}
+
+func (t *transporteMemoria) vincular(v vinculoMemoria, exchange string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.fila(v.fila)
+	t.vinculos[exchange] = append(t.vinculos[exchange], v)
+}
+
+// Padrão de rota de uma exchange topic contra a routing key, palavra por
+// palavra (separadas por ".").
+func rotaCasa(padrao, chave string) bool {
+	return palavrasCasam(strings.Split(padrao, "."), strings.Split(chave, "."))
+}
+
+func palavrasCasam(padrao, chave []string) bool {
+	if len(padrao) == 0 {
+		return len(chave) == 0
+	}
+	if padrao[0] == "#" {
+		for i := 0; i <= len(chave); i++ {
+			if palavrasCasam(padrao[1:], chave[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(chave) == 0 || padrao[0] != "*" && padrao[0] != chave[0] {
+		return false
+	}
+	return palavrasCasam(padrao[1:], chave[1:])
+}
+
+// Retorna a fila pelo nome, criando-a. Chamado com o mu travado.
+func (t *transporteMemoria) fila(nome string) chan amqp.Delivery {
+	f, ok := t.filas[nome]
+	if !ok {
+		f = make(chan amqp.Delivery, 1024)
+		t.filas[nome] = f
+	}
+	return f
+}
+
+func (t *transporteMemoria) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	t.mu.Lock()
+	var destinos []string
+	for _, v := range t.vinculos[exchange] {
+		if (v.todas || rotaCasa(v.rota, key)) && !slices.Contains(destinos, v.fila) {
+			destinos = append(destinos, v.fila)
+		}
+	}
+	if exchange == "" {
+		destinos = []string{key}
+	}
+	filas := make([]chan amqp.Delivery, 0, len(destinos))
+	for _, nome := range destinos {
+		filas = append(filas, t.fila(nome))
+	}
+	t.mu.Unlock()
+
+	for _, f := range filas {
+		select {
+		case f <- amqp.Delivery{
+			Acknowledger:    semConfirmacao{},
+			Exchange:        exchange,
+			RoutingKey:      key,
+			ContentType:     msg.ContentType,
+			ContentEncoding: msg.ContentEncoding,
+			Headers:         msg.Headers,
+			DeliveryMode:    msg.DeliveryMode,
+			Priority:        msg.Priority,
+			CorrelationId:   msg.CorrelationId,
+			ReplyTo:         msg.ReplyTo,
+			MessageId:       msg.MessageId,
+			Timestamp:       msg.Timestamp,
+			Body:            msg.Body,
+		}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Entrega as mensagens da fila. Ack e Nack são aceitos e ignorados.
+func (t *transporteMemoria) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.fila(queue), nil
+}
+
+// Acknowledger das entregas em memória: não há broker para confirmar.
+type semConfirmacao struct{}
+
+func (semConfirmacao) Ack(tag uint64, multiple bool) error           { return nil }
+func (semConfirmacao) Nack(tag uint64, multiple, requeue bool) error { return nil }
+func (semConfirmacao) Reject(tag uint64, requeue bool) error         { return nil }
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"votacao-rabbitmq/client/votacao"
+)
+
+// Fluxo completo voto -> contagem -> broadcast pelo transporte em memória,
+// sem RabbitMQ.
+func TestFluxoCompletoEmMemoria(t *testing.T) {
+	tr := novoTransporteMemoria()
+	tr.Vincular(filaVotos, exchangeVotos)
+	tr.Vincular("cliente", exchangeBroadcast)
+
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{t: tr, apuracao: novaApuracao(validador, 1, nil, nil)}
+
+	votos, _ := tr.Consume(filaVotos, "", true, false, false, false, nil)
+	go w.processar(votos)
+
+	broadcast, _ := tr.Consume("cliente", "", true, false, false, false, nil)
+
+	for _, v := range []Voto{
+		{UserID: "u1", Option: "A"},
+		{UserID: "u2", Option: "B"},
+		{UserID: "u1", Option: "C"},
+	} {
+		body, _ := json.Marshal(v)
+		err := tr.PublishWithContext(context.Background(), exchangeVotos, "voto", false, false, amqp.Publishing{Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Um único worker processa em ordem: confirmação e parcial para cada
+	// voto aceito e o erro do voto duplicado.
+	esperado := []string{"confirmacao", "parcial", "confirmacao", "parcial", "erro"}
+	var ultima BroadcastMsg
+	for i, tipo := range esperado {
+		select {
+		case d := <-broadcast:
+			var msg BroadcastMsg
+			if err := json.Unmarshal(d.Body, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Tipo != tipo {
+				t.Fatalf("mensagem %d: tipo = %q, esperado %q", i, msg.Tipo, tipo)
+			}
+			if msg.Tipo == "parcial" {
+				ultima = msg
+			}
+			if msg.Tipo == "erro" && msg.Codigo != CodJaVotou {
+				t.Errorf("código do erro = %q, esperado %q", msg.Codigo, CodJaVotou)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("sem mensagem %d (%s) no broadcast", i, tipo)
+		}
+	}
+
+	if ultima.Result["A"] != 1 || ultima.Result["B"] != 1 || ultima.Result["C"] != 0 {
+		t.Errorf("parcial = %v, esperado A=1 B=1 C=0", ultima.Result)
+	}
+}
+
+// A biblioteca votacao e o servidor pelo mesmo transporte em memória: os
+// votos do Vote chegam ao worker e as respostas voltam como eventos do
+// Subscribe.
+func TestClienteEServidorEmMemoria(t *testing.T) {
+	tr := novoTransporteMemoria()
+	tr.Vincular(filaVotos, exchangeVotos)
+	tr.Vincular("cliente", exchangeBroadcast)
+
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{t: tr, apuracao: novaApuracao(validador, 1, nil, nil)}
+	votos, _ := tr.Consume(filaVotos, "", true, false, false, false, nil)
+	go w.processar(votos)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c := votacao.NovoClienteTransporte(tr, "", "cliente")
+	defer c.Close()
+	eventos, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []votacao.Voto{
+		{UserID: "u1", Option: "A"},
+		{UserID: "u2", Option: "B"},
+		{UserID: "u1", Option: "C"},
+	} {
+		if err := c.Vote(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var recebidos []votacao.Event
+	for len(recebidos) < 5 {
+		select {
+		case ev := <-eventos:
+			recebidos = append(recebidos, ev)
+		case <-ctx.Done():
+			t.Fatalf("eventos = %+v, esperados 5", recebidos)
+		}
+	}
+	if cf, ok := recebidos[0].(votacao.Confirmation); !ok || cf.UserID != "u1" || !slices.Equal(cf.Opcoes, []string{"A"}) {
+		t.Errorf("evento 0 = %+v, esperada a confirmação de u1 em A", recebidos[0])
+	}
+	if p, ok := recebidos[3].(votacao.PartialResult); !ok || p.Total != 2 {
+		t.Errorf("evento 3 = %+v, esperada a parcial com 2 votos", recebidos[3])
+	}
+	if e, ok := recebidos[4].(*votacao.Error); !ok || e.Codigo != CodJaVotou {
+		t.Errorf("evento 4 = %+v, esperado o erro %s", recebidos[4], CodJaVotou)
+	}
+}
+
+// Um voto recusado (ou ilegível) não para o worker: os votos seguintes
+// continuam sendo contados.
+func TestWorkerSegueAposRejeicao(t *testing.T) {
+	tr := novoTransporteMemoria()
+	tr.Vincular(filaVotos, exchangeVotos)
+	tr.Vincular("cliente", exchangeBroadcast)
+
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{t: tr, apuracao: novaApuracao(validador, 1, nil, nil)}
+
+	votos, _ := tr.Consume(filaVotos, "", true, false, false, false, nil)
+	go w.processar(votos)
+
+	broadcast, _ := tr.Consume("cliente", "", true, false, false, false, nil)
+
+	corpos := [][]byte{[]byte("{não é json")}
+	for _, v := range []Voto{
+		{UserID: "u1", Option: "D"},
+		{UserID: "u2", Option: "B"},
+	} {
+		body, _ := json.Marshal(v)
+		corpos = append(corpos, body)
+	}
+	for _, body := range corpos {
+		err := tr.PublishWithContext(context.Background(), exchangeVotos, "voto", false, false, amqp.Publishing{Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// O corpo ilegível é só registrado no log; a opção inválida recebe
+	// erro; o voto válido seguinte é confirmado e contado.
+	esperado := []string{"erro", "confirmacao", "parcial"}
+	for i, tipo := range esperado {
+		select {
+		case d := <-broadcast:
+			var msg BroadcastMsg
+			if err := json.Unmarshal(d.Body, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Tipo != tipo {
+				t.Fatalf("mensagem %d: tipo = %q, esperado %q", i, msg.Tipo, tipo)
+			}
+			if msg.Tipo == "erro" && msg.Codigo != CodOpcaoInvalida {
+				t.Errorf("código do erro = %q, esperado %q", msg.Codigo, CodOpcaoInvalida)
+			}
+			if msg.Tipo == "parcial" && msg.Result["B"] != 1 {
+				t.Errorf("parcial = %v, esperado B=1", msg.Result)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("sem mensagem %d (%s) no broadcast", i, tipo)
+		}
+	}
+
+	if r := w.apuracao.rejeicoes.copia(); r[MotivoInvalido] != 2 {
+		t.Errorf("rejeições = %v, esperado invalido=2", r)
+	}
+}
+
+// Um cliente que liga a fila depois dos anúncios da partida ("online" e
+// "opcoes") aprende as opções pelo heartbeat seguinte.
+func TestClienteTardioConheceOpcoes(t *testing.T) {
+	casos := []struct {
+		nome    string
+		opcoes  string
+		rotulos string
+		modo    string
+		pow     int
+		// Campos esperados no heartbeat.
+		lista     []string
+		intervalo string
+		apuracao  string
+	}{
+		{nome: "lista", opcoes: "A,B,C", rotulos: "A:Apple", modo: ApuracaoMaioria, lista: []string{"A", "B", "C"}},
+		{nome: "intervalo", opcoes: "RANGE:1-5", modo: ApuracaoMaioria, intervalo: "1-5"},
+		// Sem "apuracao", o cliente tardio mandaria uma opção só.
+		{nome: "aprovação", opcoes: "A,B,C", modo: ApuracaoAprovacao, lista: []string{"A", "B", "C"}, apuracao: "approval"},
+		// Sem "apuracao", o cliente tardio não mandaria o ranking.
+		{nome: "ranking", opcoes: "A,B,C", modo: ApuracaoRanking, lista: []string{"A", "B", "C"}, apuracao: "irv"},
+		// Sem a dificuldade, todo voto do cliente tardio volta com POW_REQUIRED.
+		{nome: "prova de trabalho", opcoes: "A,B,C", modo: ApuracaoMaioria, pow: 12, lista: []string{"A", "B", "C"}},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			modoAntes, powAntes := modoApuracao, dificuldadePoW
+			modoApuracao, dificuldadePoW = c.modo, c.pow
+			defer func() { modoApuracao, dificuldadePoW = modoAntes, powAntes }()
+
+			validador, err := novoValidador(c.opcoes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.rotulos != "" {
+				if err := aplicarExibicao(c.rotulos, "", validador); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Sem nenhuma fila ligada, os anúncios da partida se perdem.
+			tr := novoTransporteMemoria()
+			enviarOnline(tr, validador, novoPrazo(time.Minute))
+			enviarOpcoes(tr, validador)
+
+			tr.Vincular("tardio", exchangeBroadcast)
+			broadcast, _ := tr.Consume("tardio", "", true, false, false, false, nil)
+			if err := enviarTempo(tr, novoPrazo(time.Minute), validador); err != nil {
+				t.Fatal(err)
+			}
+
+			var msg BroadcastMsg
+			select {
+			case d := <-broadcast:
+				if err := json.Unmarshal(d.Body, &msg); err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("sem heartbeat no broadcast")
+			}
+			if msg.Tipo != "tempo" {
+				t.Fatalf("tipo = %q, esperado tempo", msg.Tipo)
+			}
+			if !slices.Equal(msg.Opcoes, c.lista) || msg.Intervalo != c.intervalo {
+				t.Errorf("opções = %v intervalo %q, esperado %v intervalo %q", msg.Opcoes, msg.Intervalo, c.lista, c.intervalo)
+			}
+			if msg.Apuracao != c.apuracao {
+				t.Errorf("apuração = %q, esperado %q", msg.Apuracao, c.apuracao)
+			}
+			if msg.DificuldadePoW != c.pow {
+				t.Errorf("dificuldade = %d, esperado %d", msg.DificuldadePoW, c.pow)
+			}
+			if c.rotulos != "" && msg.Rotulos["A"] != "Apple" {
+				t.Errorf("rótulos = %v, esperado A:Apple", msg.Rotulos)
+			}
+		})
+	}
+}
+
+// Com ORDERED_PER_USER, um corpo acima de MAX_MSG_BYTES passa pelo
+// despacho sem ser decodificado e é descartado pelo worker, sem atrasar
+// os votos seguintes.
+func TestDespachoIgnoraCorpoGrande(t *testing.T) {
+	grande, _ := json.Marshal(Voto{UserID: "u1", Option: strings.Repeat("A", 200)})
+	if u := usuarioDoDespacho(amqp.Delivery{Body: grande}, 64); u != "" {
+		t.Errorf("usuário do corpo grande = %q, esperado vazio (sem decodificar)", u)
+	}
+
+	tr := novoTransporteMemoria()
+	tr.Vincular(filaVotos, exchangeVotos)
+	tr.Vincular("cliente", exchangeBroadcast)
+
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apuracao := novaApuracao(validador, 1, nil, nil)
+	votos, _ := tr.Consume(filaVotos, "", true, false, false, false, nil)
+	for i, fila := range despacharPorUsuario(votos, 2, 64) {
+		w := &Worker{id: i, t: tr, apuracao: apuracao, maxBytes: 64}
+		go w.processar(fila)
+	}
+	broadcast, _ := tr.Consume("cliente", "", true, false, false, false, nil)
+
+	pequeno, _ := json.Marshal(Voto{UserID: "u2", Option: "B"})
+	for _, body := range [][]byte{grande, pequeno} {
+		err := tr.PublishWithContext(context.Background(), exchangeVotos, "voto", false, false, amqp.Publishing{Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	esperado := []string{"confirmacao", "parcial"}
+	for i, tipo := range esperado {
+		select {
+		case d := <-broadcast:
+			var msg BroadcastMsg
+			if err := json.Unmarshal(d.Body, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Tipo != tipo {
+				t.Fatalf("mensagem %d: tipo = %q, esperado %q", i, msg.Tipo, tipo)
+			}
+			if msg.Tipo == "parcial" && msg.Result["B"] != 1 {
+				t.Errorf("parcial = %v, esperado B=1", msg.Result)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("sem mensagem %d (%s) no broadcast", i, tipo)
+		}
+	}
+
+	// O descarte do corpo grande pode terminar depois, em outro worker.
+	limite := time.Now().Add(2 * time.Second)
+	for apuracao.rejeicoes.copia()[MotivoInvalido] != 1 {
+		if time.Now().After(limite) {
+			t.Fatalf("rejeições = %v, esperado invalido=1", apuracao.rejeicoes.copia())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Com VOTER_IDENTITY=cert, o despacho segue o user_id do certificado: um
+// cliente que troca o userId do corpo continua fixado no mesmo worker.
+func TestDespachoPelaIdentidadeDoCertificado(t *testing.T) {
+	identidadePeloBroker = true
+	defer func() { identidadePeloBroker = false }()
+
+	msgs := make(chan amqp.Delivery)
+	filas := despacharPorUsuario(msgs, 4, 0)
+
+	recebidas := make([]int, len(filas))
+	var wg sync.WaitGroup
+	for i, f := range filas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range f {
+				recebidas[i]++
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		body, _ := json.Marshal(Voto{UserID: fmt.Sprintf("falso-%d", i), Option: "A"})
+		msgs <- amqp.Delivery{UserId: "ana", Body: body}
+	}
+	close(msgs)
+	wg.Wait()
+
+	if !slices.Contains(recebidas, 20) {
+		t.Errorf("votos por worker = %v, esperado os 20 no mesmo worker", recebidas)
+	}
+}
+
+// Com BROADCAST_LANGS, cada cliente recebe as respostas só no seu idioma
+// e as mensagens sem idioma uma vez, pela rota "broadcast".
+func TestBroadcastPorIdioma(t *testing.T) {
+	idiomasBroadcast = []string{"pt-BR", "en"}
+	defer func() { idiomasBroadcast = nil }()
+
+	if d := idiomasDestino("en"); !slices.Equal(d, []string{"pt-BR", "en"}) {
+		t.Errorf("destinos de en = %v", d)
+	}
+	if d := idiomasDestino("es"); !slices.Equal(d, []string{"pt-BR", "en", "es"}) {
+		t.Errorf("destinos de es = %v, esperado a lista mais es", d)
+	}
+	if d := idiomasDestino("es.#"); !slices.Equal(d, []string{"pt-BR", "en"}) {
+		t.Errorf("destinos de um idioma inválido = %v, esperado só a lista", d)
+	}
+	if len(idiomasBroadcast) != 2 {
+		t.Fatalf("idiomasDestino alterou a lista: %v", idiomasBroadcast)
+	}
+
+	tr := novoTransporteMemoria()
+	filas := map[string]<-chan amqp.Delivery{}
+	for _, lang := range []string{"pt-BR", "en", "es"} {
+		tr.VincularRota(lang, exchangeBroadcast, rotaBroadcast)
+		tr.VincularRota(lang, exchangeBroadcast, rotaIdioma(lang))
+		filas[lang], _ = tr.Consume(lang, "", true, false, false, false, nil)
+	}
+	recebidas := func(lang string) []BroadcastMsg {
+		var msgs []BroadcastMsg
+		for {
+			select {
+			case d := <-filas[lang]:
+				var msg BroadcastMsg
+				json.Unmarshal(d.Body, &msg)
+				msgs = append(msgs, msg)
+			default:
+				return msgs
+			}
+		}
+	}
+
+	// Voto em espanhol, fora da lista: os da lista recebem a tradução e o
+	// eleitor, na rota do próprio idioma.
+	if err := enviarErro(tr, "u1", "es", CodOpcaoInvalida); err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range []string{"pt-BR", "en", "es"} {
+		msgs := recebidas(lang)
+		if len(msgs) != 1 || msgs[0].Mensagem != catalogo[lang][CodOpcaoInvalida] {
+			t.Errorf("%s recebeu %+v, esperado um erro no próprio idioma", lang, msgs)
+		}
+	}
+
+	if err := enviarErro(tr, "u2", "en", CodOpcaoInvalida); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := recebidas("es"); len(msgs) != 0 {
+		t.Errorf("es recebeu %+v de um voto em inglês", msgs)
+	}
+	recebidas("pt-BR")
+	recebidas("en")
+
+	if err := enviarParcial(tr, BroadcastMsg{Result: map[string]int{"A": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, lang := range []string{"pt-BR", "en", "es"} {
+		if msgs := recebidas(lang); len(msgs) != 1 || msgs[0].Tipo != "parcial" {
+			t.Errorf("%s recebeu %+v, esperada uma parcial", lang, msgs)
+		}
+	}
+}
+
+// Um cliente da biblioteca no idioma padrão (pt-BR), fora de
+// BROADCAST_LANGS, recebe a confirmação: o Vote manda o idioma do
+// cliente, e o servidor responde também na rota dele.
+func TestBibliotecaForaDosIdiomas(t *testing.T) {
+	idiomasBroadcast = []string{"en", "es"}
+	defer func() { idiomasBroadcast = nil }()
+
+	tr := novoTransporteMemoria()
+	tr.Vincular(filaVotos, exchangeVotos)
+	tr.VincularRota("cliente", exchangeBroadcast, rotaBroadcast)
+	tr.VincularRota("cliente", exchangeBroadcast, rotaIdioma("pt-BR"))
+
+	validador, err := novoValidador("A,B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{t: tr, apuracao: novaApuracao(validador, 1, nil, nil)}
+	votos, _ := tr.Consume(filaVotos, "", true, false, false, false, nil)
+	go w.processar(votos)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c := votacao.NovoClienteTransporte(tr, "", "cliente")
+	eventos, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Vote(ctx, votacao.Voto{UserID: "ana", Option: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-eventos:
+		if cf, ok := ev.(votacao.Confirmation); !ok || cf.Mensagem != catalogo["pt-BR"][CodVotoRegistrado] {
+			t.Errorf("evento = %+v, esperada a confirmação em pt-BR", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("sem confirmação no idioma do cliente")
+	}
+	// A parcial é a última publicação do voto; esperá-la evita que o
+	// worker ainda leia idiomasBroadcast depois da restauração.
+	aguardarParcial(t, ctx, eventos)
+}
+
+func aguardarParcial(t *testing.T, ctx context.Context, eventos <-chan votacao.Event) {
+	t.Helper()
+	for {
+		select {
+		case ev := <-eventos:
+			if _, ok := ev.(votacao.PartialResult); ok {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("sem a parcial depois da confirmação")
+		}
+	}
+}
+
+// O ranking de um voto da biblioteca chega ao servidor com TALLY=irv.
+func TestBibliotecaVotaComRanking(t *testing.T) {
+	tr := novoTransporteMemoria()
+	tr.Vincular(filaVotos, exchangeVotos)
+	tr.Vincular("cliente", exchangeBroadcast)
+
+	validador, err := novoValidador("A,B,C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := novaApuracao(validador, 3, nil, nil)
+	a.modo = ApuracaoRanking
+	w := &Worker{t: tr, apuracao: a}
+	votos, _ := tr.Consume(filaVotos, "", true, false, false, false, nil)
+	go w.processar(votos)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c := votacao.NovoClienteTransporte(tr, "", "cliente")
+	eventos, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Vote(ctx, votacao.Voto{UserID: "ana", Ranking: []string{"B", "A"}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-eventos:
+		if _, ok := ev.(votacao.Confirmation); !ok {
+			t.Fatalf("evento = %+v, esperada a confirmação", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("sem confirmação do voto com ranking")
+	}
+	aguardarParcial(t, ctx, eventos)
+	if final := a.resultadoFinal(); final.Result["B"] != 1 || final.Vencedor != "B" {
+		t.Errorf("final = %+v, esperado B na primeira preferência e vencedor", final)
+	}
+}
+
+// Padrões de rota de exchange topic no transporte em memória.
+func TestRotaTopicEmMemoria(t *testing.T) {
+	casos := []struct {
+		padrao, chave string
+		casa          bool
+	}{
+		{"broadcast", "broadcast", true},
+		{"broadcast", "broadcast.en", false},
+		{"broadcast.*", "broadcast.en", true},
+		{"broadcast.*", "broadcast", false},
+		{"broadcast.#", "broadcast", true},
+		{"audit.#", "audit.A.C", true},
+		{"#", "", true},
+		{"broadcast.en", "broadcast.es", false},
+	}
+	for _, c := range casos {
+		if got := rotaCasa(c.padrao, c.chave); got != c.casa {
+			t.Errorf("rotaCasa(%q, %q) = %v, esperado %v", c.padrao, c.chave, got, c.casa)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Ligação extra da fila de votos a uma exchange de outra origem (ex.: um
+// gateway web e um app móvel), configurada por VOTE_BINDINGS. Os votos de
+// todas as ligações caem na mesma fila, nos mesmos workers e na mesma
+// contagem.
+type Vinculo struct {
+	exchange string
+	rota     string
+	// Tipo da exchange ao declará-la: direct (padrão), topic ou fanout.
+	tipo string
+}
+
+func (v Vinculo) String() string {
+	return fmt.Sprintf("%s:%s (%s)", v.exchange, v.rota, v.tipo)
+}
+
+// Ligações no formato do log, para o /config.
+func nomesVinculos(vinculos []Vinculo) []string {
+	var nomes []string
+	for _, v := range vinculos {
+		nomes = append(nomes, v.String())
+	}
+	return nomes
+}
+
+// Lê as ligações no formato "exchange:rota[:tipo]", separadas por vírgula
+// (ex.: "web.votos:voto,mobile.votos:votos.#:topic").
+func lerVinculos(spec string) ([]Vinculo, error) {
+	var vinculos []Vinculo
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		partes := strings.Split(item, ":")
+		if len(partes) < 2 || len(partes) > 3 || partes[0] == "" {
+			return nil, fmt.Errorf("ligação %q fora do formato exchange:rota[:tipo]", item)
+		}
+		v := Vinculo{exchange: partes[0], rota: partes[1], tipo: "direct"}
+		if len(partes) == 3 {
+			switch partes[2] {
+			case "direct", "topic", "fanout":
+				v.tipo = partes[2]
+			default:
+				return nil, fmt.Errorf("tipo de exchange inválido em %q: %q", item, partes[2])
+			}
+		}
+		vinculos = append(vinculos, v)
+	}
+	return vinculos, nil
+}
+
+// Declara a exchange de cada ligação e a liga à fila de votos. Cada
+// ligação usa um canal próprio, porque um erro de declaração (ex.:
+// exchange já existente com outro tipo) fecha o canal, e o principal
+// precisa continuar aberto para os broadcasts.
+func declararVinculos(conn *amqp.Connection, fila string, vinculos []Vinculo, duravel bool) error {
+	for _, v := range vinculos {
+		ch, err := conn.Channel()
+		if err != nil {
+			return err
+		}
+		err = ch.ExchangeDeclare(v.exchange, v.tipo, duravel, false, false, false, nil)
+		if err == nil {
+			err = ch.QueueBind(fila, v.rota, v.exchange, false, nil)
+		}
+		ch.Close()
+		if err != nil {
+			return fmt.Errorf("ligação %s: %w", v, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Corpo enviado ao RESULT_WEBHOOK quando a votação encerra.
+type ResultadoWebhook struct {
+	Resultado   map[string]int     `json:"resultado"`
+	Percentuais map[string]float64 `json:"percentuais"`
+	Total       int                `json:"total"`
+	Vencedor    string             `json:"vencedor,omitempty"`
+}
+
+// Prazo de cada tentativa de entrega do webhook.
+const webhookTimeout = 5 * time.Second
+
+// Envia o resultado final ao webhook, tentando de novo conforme a política
+// em caso de falha de rede ou resposta fora da faixa 2xx.
+func enviarWebhook(url string, final BroadcastMsg, politica PoliticaEntrega) error {
+	payload := ResultadoWebhook{
+		Resultado:   final.Result,
+		Percentuais: map[string]float64{},
+		Vencedor:    final.Vencedor,
+	}
+	for _, n := range final.Result {
+		payload.Total += n
+	}
+	// Na aprovação, o percentual é sobre os votantes.
+	base := payload.Total
+	if final.Apuracao == ApuracaoAprovacao {
+		base = final.Votantes
+	}
+	for op, n := range final.Result {
+		if base > 0 {
+			payload.Percentuais[op] = float64(n) * 100 / float64(base)
+		} else {
+			payload.Percentuais[op] = 0
+		}
+	}
+	body, _ := json.Marshal(payload)
+
+	return politica.entregar("webhook", func() error {
+		return postarWebhook(url, body)
+	})
+}
+
+func postarWebhook(url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	log.Printf("Webhook: resposta %s\n", resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
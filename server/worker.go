@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"votacao-rabbitmq/client/votacao"
+)
+
+// Worker do pool: valida cada voto na Apuracao e publica a confirmação,
+// o erro ou a parcial pelo Transport.
+type Worker struct {
+	id           int
+	t            Transport
+	apuracao     *Apuracao
+	maxBytes     int
+	auditStream  bool
+	confirmDelay time.Duration
+	// Fila de envio das parciais; nil = publica direto do worker.
+	parciais *FilaParciais
+	// Modo de medição (BENCH_MODE); nil = publica normalmente.
+	bench *Bench
+	// Tempo ocupado, para o ajuste do prefetch (PREFETCH_AUTO); nil = não mede.
+	ocupacao *Ocupacao
+}
+
+// Processa os votos até o canal de entrega ser fechado.
+func (w *Worker) processar(entrada <-chan amqp.Delivery) {
+	// Loop principal do worker: processa mensagens concorrentemente
+	for msg := range entrada {
+		if w.ocupacao == nil {
+			w.tratar(msg)
+			continue
+		}
+		inicio := time.Now()
+		w.tratar(msg)
+		w.ocupacao.somar(time.Since(inicio))
+	}
+}
+
+// Processa um voto dentro do span que continua o trace do cliente.
+func (w *Worker) tratar(msg amqp.Delivery) {
+	// Pedido de parcial completa de um cliente que perdeu deltas.
+	if msg.RoutingKey == rotaSnapshot {
+		msg.Ack(false)
+		if liberarSnapshot(time.Now()) {
+			if err := enviarParcial(w.t, w.apuracao.parcialCompleta()); err != nil {
+				log.Printf("[Worker %d] Erro ao enviar parcial completa: %v\n", w.id, err)
+			}
+		}
+		return
+	}
+
+	ctx, span := tracer.Start(contextoDaEntrega(msg), "voto.processar", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+
+	// Corpo acima do limite: descarta sem interpretar, para que uma
+	// enxurrada de mensagens gigantes não pressione a memória.
+	if w.maxBytes > 0 && len(msg.Body) > w.maxBytes {
+		log.Printf("[Worker %d] Mensagem de %d bytes descartada (limite %d)\n", w.id, len(msg.Body), w.maxBytes)
+		w.apuracao.rejeicoes.contar(MotivoInvalido)
+		msg.Nack(false, false)
+		return
+	}
+	defer msg.Ack(false)
+
+	var v Voto
+
+	// Decodifica o voto no codec indicado pelo ContentType.
+	if err := codecDoTipo(msg.ContentType).Unmarshal(msg.Body, &v); err != nil {
+		log.Printf("[Worker %d] Erro ao interpretar voto: %v\n", w.id, err)
+		w.apuracao.rejeicoes.contar(MotivoInvalido)
+		span.RecordError(err)
+		return
+	}
+	v.reentrega = msg.Redelivered
+	if identidadePeloBroker {
+		var codigo string
+		if v, codigo = identificarPeloBroker(msg, v); codigo != "" {
+			w.apuracao.rejeicoes.contarCodigo(codigo)
+			w.recusar(v.UserID, v.Lang, codigo)
+			return
+		}
+	}
+	v = identificarCedula(v)
+
+	// Controles e UTF-8 inválido não chegam à contagem. Com o próprio
+	// userId inválido, não há a quem responder sem repetir os caracteres.
+	if campo := campoInvalido(v); campo != "" {
+		log.Printf("[Worker %d] Voto recusado: caracteres inválidos em %s (%q)\n", w.id, campo, v.UserID)
+		w.apuracao.rejeicoes.contarCodigo(CodCaracteres)
+		if campo != "userId" {
+			w.recusar(v.UserID, v.Lang, CodCaracteres)
+		}
+		return
+	}
+
+	// A prova de trabalho vale para o remetente; as procurações viajam no
+	// mesmo voto e não pedem outra.
+	if !votacao.ProvaValida(v.UserID, v.Pow, dificuldadePoW) {
+		w.apuracao.rejeicoes.contarCodigo(CodProvaTrabalho)
+		w.recusar(v.UserID, v.Lang, CodProvaTrabalho)
+		return
+	}
+
+	// Com procuração, o voto vira uma cédula por representado, além da
+	// do remetente; cada uma é contada e respondida separadamente.
+	cedulas, codigo := w.apuracao.delegacoes.cedulas(v)
+	if codigo != "" {
+		w.apuracao.rejeicoes.contarCodigo(codigo)
+		w.recusar(v.UserID, v.Lang, codigo)
+		return
+	}
+
+	d := w.contar(ctx, cedulas[0])
+	span.SetAttributes(
+		attribute.String("voto.user_id", v.UserID),
+		attribute.String("voto.codigo", d.Codigo),
+		attribute.Bool("voto.retentativa", d.Retentativa),
+		attribute.Int("voto.procuracoes", len(cedulas)-1),
+	)
+	for _, c := range cedulas[1:] {
+		w.contar(ctx, c)
+	}
+}
+
+// Publica a recusa de um voto; no BENCH_MODE, não publica nada.
+func (w *Worker) recusar(userID, lang, codigo string) {
+	if w.bench != nil {
+		return
+	}
+	if err := enviarErro(w.t, userID, lang, codigo); err != nil {
+		log.Printf("[Worker %d] Erro ao enviar erro: %v\n", w.id, err)
+	}
+}
+
+// Conta uma cédula e publica a confirmação, o erro e a parcial.
+func (w *Worker) contar(ctx context.Context, v Voto) Decisao {
+	d := w.apuracao.processarVotoCtx(ctx, v)
+	if w.bench != nil {
+		w.bench.registrar()
+		return d
+	}
+
+	switch {
+	case d.Codigo != "":
+		w.recusar(v.UserID, v.Lang, d.Codigo)
+		return d
+
+	case d.Retentativa:
+		if v.NoConfirm {
+			return d
+		}
+		log.Printf("[Worker %d] Retentativa do voto %s de %s; reenviando confirmação\n", w.id, v.Nonce, v.UserID)
+		recibo := gerarRecibo(segredoRecibo, v.UserID, d.Escolhas, d.Quando)
+		if err := enviarConfirmacao(w.t, v.UserID, v.Lang, d.Escolhas, d.Anteriores, recibo); err != nil {
+			log.Printf("[Worker %d] Erro ao enviar confirmação: %v\n", w.id, err)
+		}
+		return d
+	}
+
+	switch {
+	case v.procurador != "":
+		log.Printf("[Worker %d] Voto por procuração: %s -> %s (por %s)\n", w.id, v.UserID, strings.Join(d.Escolhas, ","), v.procurador)
+	case d.Anteriores != nil:
+		log.Printf("[Worker %d] Revoto: %s %s -> %s\n", w.id, v.UserID, strings.Join(d.Anteriores, ","), strings.Join(d.Escolhas, ","))
+	default:
+		log.Printf("[Worker %d] Voto recebido: %s -> %s\n", w.id, v.UserID, strings.Join(d.Escolhas, ","))
+	}
+
+	if logVotos != nil {
+		if err := logVotos.registrarVoto(v.UserID, d.Escolhas, v.Nonce, d.Quando); err != nil {
+			log.Printf("[Worker %d] Erro ao gravar log de votos: %v\n", w.id, err)
+		}
+	}
+
+	if destinoEventos != nil {
+		destinoEventos.voto(v.UserID, eventoVoto(v.UserID, d, v.procurador))
+	}
+
+	if w.auditStream {
+		if err := publicarAuditoria(w.t, v.UserID, d.Escolhas, d.Quando); err != nil {
+			log.Printf("[Worker %d] Erro ao publicar auditoria: %v\n", w.id, err)
+		}
+	}
+
+	if w.confirmDelay > 0 {
+		time.Sleep(w.confirmDelay)
+	}
+
+	if !v.NoConfirm {
+		_, confirmar := tracer.Start(ctx, "voto.confirmacao", trace.WithSpanKind(trace.SpanKindProducer))
+		recibo := gerarRecibo(segredoRecibo, v.UserID, d.Escolhas, d.Quando)
+		if err := enviarConfirmacao(w.t, v.UserID, v.Lang, d.Escolhas, d.Anteriores, recibo); err != nil {
+			log.Printf("[Worker %d] Erro ao enviar confirmação: %v\n", w.id, err)
+			confirmar.RecordError(err)
+		}
+		confirmar.End()
+	}
+	if w.parciais != nil {
+		w.parciais.enfileirar(d.Parcial)
+		return d
+	}
+	if err := enviarParcial(w.t, d.Parcial); err != nil {
+		log.Printf("[Worker %d] Erro ao enviar parcial: %v\n", w.id, err)
+	}
+	return d
+}